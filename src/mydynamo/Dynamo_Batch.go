@@ -0,0 +1,203 @@
+package mydynamo
+
+import "log"
+
+//Gets several keys from this server, do not contact other servers.
+//A key that cannot be read gets its error and does not fail the others
+func (s *DynamoServer) GetLocalBatch(keys []string, result *[]LocalGetResult) error {
+	results := make([]LocalGetResult, len(keys))
+	for idx, key := range keys {
+		err := s.GetLocal(key, &results[idx].Result)
+		if err != nil {
+			results[idx] = LocalGetResult{Err: err.Error()}
+		}
+	}
+	*result = results
+	return nil
+}
+
+//Puts several values to this server, do not replicate them to other servers.
+//A value that cannot be written gets its error and does not fail the others
+func (s *DynamoServer) PutLocalBatch(values []IndexedPutArgs, result *[]LocalPutResult) error {
+	results := make([]LocalPutResult, len(values))
+	for idx, value := range values {
+		err := s.PutLocalIndexed(value, &results[idx].Success)
+		if err != nil {
+			results[idx] = LocalPutResult{Err: err.Error()}
+		}
+	}
+	*result = results
+	return nil
+}
+
+//Gets several keys of a bucket, reading each from R replicas.
+//Keys are grouped by replica so that every other node receives a single GetLocalBatch call
+func (s *DynamoServer) MultiGet(args MultiGetArgs, result *MultiGetResult) error {
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+
+	results := make([]KeyGetResult, len(args.Keys))
+	keys := make([]string, len(args.Keys))
+	cnt := make([]int, len(args.Keys))
+	for idx, key := range args.Keys {
+		results[idx].Key = key
+		keys[idx], err = clientStorageKey(args.Bucket, key)
+		if err != nil {
+			results[idx].Err = err.Error()
+			continue
+		}
+
+		var local DynamoResult
+		err = s.GetLocal(keys[idx], &local)
+		if err != nil {
+			results[idx].Err = err.Error()
+			continue
+		}
+		results[idx].Result.EntryList = local.EntryList
+	}
+
+	// one batched read per replica, for the keys still short of r - 1 answers
+	for _, node := range s.replicas(props) {
+		if node.Address == s.selfNode.Address && node.Port == s.selfNode.Port {
+			continue
+		}
+
+		batch := make([]int, 0)
+		for idx := range keys {
+			if results[idx].Err == "" && cnt[idx] < r-1 {
+				batch = append(batch, idx)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		batchKeys := make([]string, len(batch))
+		for i, idx := range batch {
+			batchKeys[i] = keys[idx]
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		otherResults := clientInstance.GetLocalBatch(batchKeys)
+		if otherResults == nil || len(otherResults) != len(batch) {
+			continue
+		}
+		log.Println("multiget batch from", node.Address+":"+node.Port, len(batch))
+
+		for i, idx := range batch {
+			if otherResults[i].Err != "" {
+				continue
+			}
+			cnt[idx]++
+			results[idx].Result.EntryList = reconcile(results[idx].Result.EntryList, otherResults[i].Result.EntryList)
+		}
+	}
+
+	for idx := range results {
+		if results[idx].Err != "" {
+			continue
+		}
+		results[idx].Result.EntryList = s.resolve(keys[idx], results[idx].Result.EntryList)
+		results[idx].Result.Context = CombineContexts(results[idx].Result.EntryList)
+		results[idx].Result.Replicas = cnt[idx] + 1
+		results[idx].Result.QuorumMet = cnt[idx]+1 >= r
+		if !results[idx].Result.QuorumMet && !args.AllowPartial {
+			results[idx].Result.EntryList = nil
+			results[idx].Err = ErrQuorumNotMet.Error()
+		}
+	}
+	*result = MultiGetResult{Results: results}
+	return nil
+}
+
+//Puts several values into a bucket, writing each to W replicas.
+//Values are grouped by replica so that every other node receives a single PutLocalBatch call
+func (s *DynamoServer) MultiPut(args MultiPutArgs, result *MultiPutResult) error {
+	props := s.bucket(args.Bucket)
+	w, err := writeQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+
+	results := make([]KeyPutResult, len(args.Values))
+	values := make([]IndexedPutArgs, len(args.Values))
+	cnt := make([]int, len(args.Values))
+	for idx, value := range args.Values {
+		results[idx].Key = value.Key
+		key, err := clientStorageKey(args.Bucket, value.Key)
+		if err != nil {
+			results[idx].Err = err.Error()
+			continue
+		}
+
+		stamped := NewPutArgs(key, NewContext(s.stamp(key, value.Context.Clock)), value.Value)
+		values[idx] = s.compressPut(IndexedPutArgs{
+			Value:    stamped,
+			Metadata: assignMetadata(ObjectMetadata{}, stamped),
+		})
+
+		var res bool
+		err = s.PutLocalIndexed(values[idx], &res)
+		if err != nil {
+			results[idx].Err = err.Error()
+			continue
+		}
+		if res {
+			results[idx].Result.Replicas = 1
+		}
+	}
+
+	// one batched write per replica, for the values still short of w - 1 acknowledgements
+	for _, node := range s.replicas(props) {
+		if node.Address == s.selfNode.Address && node.Port == s.selfNode.Port {
+			continue
+		}
+
+		batch := make([]int, 0)
+		for idx := range values {
+			if results[idx].Err == "" && cnt[idx] < w-1 {
+				batch = append(batch, idx)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		batchValues := make([]IndexedPutArgs, len(batch))
+		for i, idx := range batch {
+			batchValues[i] = values[idx]
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		succ := clientInstance.PutLocalBatch(batchValues)
+		if succ == nil || len(succ) != len(batch) {
+			continue
+		}
+		log.Println("multiput batch to", node.Address+":"+node.Port, len(batch))
+
+		for i, idx := range batch {
+			if succ[i].Success {
+				cnt[idx]++
+				results[idx].Result.Replicas++
+			}
+		}
+	}
+
+	for idx := range results {
+		if results[idx].Err != "" {
+			continue
+		}
+		results[idx].Result.Success = cnt[idx] == w-1
+		results[idx].Result.Context = values[idx].Value.Context
+		results[idx].Result.Siblings = s.siblings(values[idx].Value.Key)
+	}
+	*result = MultiPutResult{Results: results}
+	return nil
+}
@@ -0,0 +1,282 @@
+package mydynamo
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+//Bucket used by requests that do not name one
+const DEFAULT_BUCKET string = ""
+
+//Separates the bucket name from the key in storage keys, see storageKey
+const BUCKET_SEPARATOR string = "\x00"
+
+//Causality modes, usable as BucketProps.Causality
+const CAUSALITY_VECTOR_CLOCK string = "vclock" //Coordinators increment their entry of the client's clock
+const CAUSALITY_DVV string = "dvv"             //Coordinators add a dot to the client's clock, see Dot
+
+//Time between sweeps dropping the keys whose TTL has passed
+const EXPIRY_SWEEP_INTERVAL time.Duration = time.Second
+
+var ErrInvalidBucket = errors.New("invalid bucket")
+var ErrInvalidKey = errors.New("invalid key")
+
+//Replication, conflict resolution and expiry settings of a named bucket.
+//Zero values fall back to the node defaults
+type BucketProps struct {
+	Name         string
+	N            int    //Number of replicas, taken from the front of the preference list
+	R            int    //Number of replicas to read from on each Get
+	W            int    //Number of replicas to write to on each Put
+	ConflictMode string //How concurrent versions are resolved
+	Causality    string //How versions are ordered, CAUSALITY_VECTOR_CLOCK or CAUSALITY_DVV
+	Compression  string //Codec values are stored and replicated with, CODEC_NONE, CODEC_GZIP or CODEC_FLATE
+	TTL          int    //Seconds a value lives after its last write, 0 to keep values forever
+	Version      int    //Bumped on every change; replicas keep the highest version they have seen
+}
+
+//Returns ErrInvalidBucket unless props are valid settings for a bucket of a cluster of
+//n nodes (0 when unknown): a bucket name, replication settings within n and each other,
+//and a known conflict mode, causality and codec
+func ValidateBucket(props BucketProps, n int) error {
+	if props.Name == DEFAULT_BUCKET || strings.Contains(props.Name, BUCKET_SEPARATOR) {
+		return ErrInvalidBucket
+	}
+	if props.N < 0 || props.R < 0 || props.W < 0 || props.TTL < 0 ||
+		(n > 0 && (props.N > n || props.R > n || props.W > n)) ||
+		(props.N > 0 && (props.R > props.N || props.W > props.N)) {
+		return ErrInvalidBucket
+	}
+	if _, found := lookupResolver(props.ConflictMode); props.ConflictMode != "" && !found {
+		return ErrInvalidBucket
+	}
+	if props.Causality != "" && props.Causality != CAUSALITY_VECTOR_CLOCK && props.Causality != CAUSALITY_DVV {
+		return ErrInvalidBucket
+	}
+	if !validCodec(props.Compression) {
+		return ErrInvalidBucket
+	}
+	return nil
+}
+
+//Adds a bucket to this server's metadata. Used at startup so every node shares the
+//buckets from the config file, once checked with ValidateBucket; use SetBucket to change
+//buckets on a running cluster
+func (s *DynamoServer) AddBucket(props BucketProps) {
+	s.bucketLock.Lock()
+	defer s.bucketLock.Unlock()
+	s.buckets[props.Name] = props
+}
+
+//Creates or updates a bucket and propagates it to every node in the preference list
+func (s *DynamoServer) SetBucket(props BucketProps, result *BucketProps) error {
+	if err := ValidateBucket(props, len(s.preferenceList)); err != nil {
+		return err
+	}
+
+	s.bucketLock.Lock()
+	props.Version = s.buckets[props.Name].Version + 1
+	s.buckets[props.Name] = props
+	s.bucketLock.Unlock()
+	for _, node := range s.preferenceList {
+		if node.Address == s.selfNode.Address && node.Port == s.selfNode.Port {
+			continue
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() == nil {
+			clientInstance.PutBucketLocal(props)
+		}
+	}
+
+	*result = props
+	return nil
+}
+
+//Stores bucket metadata received from another node, if it is newer than ours
+func (s *DynamoServer) PutBucketLocal(props BucketProps, result *bool) error {
+	s.bucketLock.Lock()
+	defer s.bucketLock.Unlock()
+	if current, found := s.buckets[props.Name]; found && current.Version >= props.Version {
+		*result = false
+		return nil
+	}
+	s.buckets[props.Name] = props
+	*result = true
+	return nil
+}
+
+//Returns the settings of a bucket, with node defaults filled in
+func (s *DynamoServer) GetBucket(name string, result *BucketProps) error {
+	*result = s.bucket(name)
+	return nil
+}
+
+//Returns a copy of the settings of every named bucket, as stored
+func (s *DynamoServer) bucketSnapshot() map[string]BucketProps {
+	s.bucketLock.RLock()
+	defer s.bucketLock.RUnlock()
+	snapshot := make(map[string]BucketProps, len(s.buckets))
+	for name, props := range s.buckets {
+		snapshot[name] = props
+	}
+	return snapshot
+}
+
+//Returns the settings of a bucket, with unset fields replaced by the node defaults
+func (s *DynamoServer) bucket(name string) BucketProps {
+	s.bucketLock.RLock()
+	props, found := s.buckets[name]
+	s.bucketLock.RUnlock()
+	if !found {
+		props = BucketProps{Name: name}
+	}
+	n := len(s.preferenceList)
+	if n == 0 {
+		n = 1
+	}
+	if props.N == 0 || props.N > n {
+		props.N = n
+	}
+	if props.R == 0 {
+		props.R = s.rValue
+	}
+	if props.W == 0 {
+		props.W = s.wValue
+	}
+	if props.ConflictMode == "" {
+		props.ConflictMode = CONFLICT_SIBLINGS
+	}
+	if props.Causality == "" {
+		props.Causality = CAUSALITY_VECTOR_CLOCK
+	}
+	return props
+}
+
+//Returns the nodes holding replicas of keys in the given bucket
+func (s *DynamoServer) replicas(props BucketProps) []DynamoNode {
+	if props.N < len(s.preferenceList) {
+		return s.preferenceList[:props.N]
+	}
+	return s.preferenceList
+}
+
+//Returns when a version of key written at written expires, or false if its bucket has no TTL
+func (s *DynamoServer) deadline(key string, written time.Time) (time.Time, bool) {
+	bucket, _ := splitStorageKey(key)
+	props := s.bucket(bucket)
+	if props.TTL <= 0 {
+		return time.Time{}, false
+	}
+	return written.Add(time.Duration(props.TTL) * time.Second), true
+}
+
+//Sets the expiry of a key that was just written to the TTL of its bucket after the
+//latest write time of its versions, so replicas agree on it however late they receive them.
+//Callers hold storeLock
+func (s *DynamoServer) touch(key string) {
+	var latest time.Time
+	for _, info := range s.versions[key] {
+		if info.metadata.LastModified.After(latest) {
+			latest = info.metadata.LastModified
+		}
+	}
+	if latest.IsZero() {
+		latest = time.Now()
+	}
+	if deadline, ok := s.deadline(key, latest); ok {
+		s.expiry[key] = deadline
+	}
+}
+
+//Drops a key whose TTL has passed. Returns true if the key was dropped.
+//Callers hold storeLock
+func (s *DynamoServer) expire(key string) bool {
+	deadline, found := s.expiry[key]
+	if !found || time.Now().Before(deadline) {
+		return false
+	}
+	log.Println(DYNAMO_SERVER, "expiring key", key)
+	delete(s.storage, key)
+	delete(s.expiry, key)
+	s.unspill(key)
+	s.index.remove(key)
+	s.updateVersions(key, nil, nil)
+	s.account(key)
+	return true
+}
+
+//Drops key, as expire does, for callers that do not hold storeLock
+func (s *DynamoServer) checkExpiry(key string) bool {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	return s.expire(key)
+}
+
+//Drops every key whose TTL has passed, so keys that are written once and never read
+//again do not stay in memory. Returns the number of keys dropped
+func (s *DynamoServer) sweepExpired() int {
+	now := time.Now()
+	s.storeLock.Lock()
+	keys := make([]string, 0)
+	for key, deadline := range s.expiry {
+		if !now.Before(deadline) {
+			keys = append(keys, key)
+		}
+	}
+	s.storeLock.Unlock()
+
+	// one key at a time, so that requests are not held up by a long sweep
+	dropped := 0
+	for _, key := range keys {
+		if s.checkExpiry(key) {
+			dropped++
+		}
+	}
+	return dropped
+}
+
+//Runs sweepExpired every EXPIRY_SWEEP_INTERVAL while the server is up
+func (s *DynamoServer) expiryLoop() {
+	for {
+		time.Sleep(EXPIRY_SWEEP_INTERVAL)
+		if s.crashed {
+			continue
+		}
+		s.sweepExpired()
+	}
+}
+
+//Returns the key under which a bucket's key is stored on each node.
+//Keys of the default bucket are stored as is, so older clients keep working
+func storageKey(bucket string, key string) string {
+	if bucket == DEFAULT_BUCKET {
+		return key
+	}
+	return BUCKET_SEPARATOR + bucket + BUCKET_SEPARATOR + key
+}
+
+//Splits a storage key back into its bucket and key
+func splitStorageKey(key string) (string, string) {
+	if !strings.HasPrefix(key, BUCKET_SEPARATOR) {
+		return DEFAULT_BUCKET, key
+	}
+	parts := strings.SplitN(key[len(BUCKET_SEPARATOR):], BUCKET_SEPARATOR, 2)
+	if len(parts) != 2 {
+		return DEFAULT_BUCKET, key
+	}
+	return parts[0], parts[1]
+}
+
+//Returns the storage key for a client request, rejecting keys that could collide
+//with keys of another bucket
+func clientStorageKey(bucket string, key string) (string, error) {
+	if strings.HasPrefix(key, BUCKET_SEPARATOR) {
+		return "", ErrInvalidKey
+	}
+	if strings.Contains(bucket, BUCKET_SEPARATOR) {
+		return "", ErrInvalidBucket
+	}
+	return storageKey(bucket, key), nil
+}
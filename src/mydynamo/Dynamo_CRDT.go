@@ -0,0 +1,404 @@
+package mydynamo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+)
+
+//Kinds of replicated data types a value can hold
+type CRDTType int
+
+const (
+	CRDT_COUNTER  CRDTType = iota + 1 //PN-counter
+	CRDT_SET                          //Observed-remove set
+	CRDT_REGISTER                     //Last-writer-wins register
+	CRDT_MAP                          //Map of named CRDTs
+)
+
+//Prefix marking a stored value as an encoded CRDT rather than opaque bytes
+const CRDT_MAGIC string = "\x00crdt"
+
+var ErrCRDTType = errors.New("value is not a CRDT of the requested type")
+
+//Counter supporting increments and decrements: per node totals of each
+type PNCounter struct {
+	P map[string]int64
+	N map[string]int64
+}
+
+//Set where an add wins over a concurrent remove. Every add is recorded under a
+//unique tag, and a remove only hides the tags it has observed
+type ORSet struct {
+	Adds    map[string]map[string]bool //Element -> tags of its adds
+	Removes map[string]map[string]bool //Element -> tags of its adds that were removed
+}
+
+//Register keeping the value with the latest hybrid timestamp, then highest writer ID
+type LWWRegister struct {
+	Value     []byte
+	Timestamp int64
+	Writer    string
+}
+
+//State of a CRDT. Only the member matching Type is used
+type CRDTValue struct {
+	Type     CRDTType
+	Counter  PNCounter
+	Set      ORSet
+	Register LWWRegister
+	Map      map[string]*CRDTValue
+}
+
+//Creates an empty CRDT of the given type
+func NewCRDT(crdtType CRDTType) *CRDTValue {
+	return &CRDTValue{
+		Type: crdtType,
+		Counter: PNCounter{
+			P: make(map[string]int64),
+			N: make(map[string]int64),
+		},
+		Set: ORSet{
+			Adds:    make(map[string]map[string]bool),
+			Removes: make(map[string]map[string]bool),
+		},
+		Map: make(map[string]*CRDTValue),
+	}
+}
+
+//Encodes a CRDT for storage as an ObjectEntry value
+func EncodeCRDT(v *CRDTValue) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(CRDT_MAGIC)
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to encode CRDT", err)
+	}
+	return buf.Bytes()
+}
+
+//Decodes a value written by EncodeCRDT. Returns false if value is not a CRDT
+func DecodeCRDT(value []byte) (*CRDTValue, bool) {
+	if !bytes.HasPrefix(value, []byte(CRDT_MAGIC)) {
+		return nil, false
+	}
+	decoded := NewCRDT(0)
+	err := gob.NewDecoder(bytes.NewReader(value[len(CRDT_MAGIC):])).Decode(decoded)
+	if err != nil {
+		return nil, false
+	}
+	decoded.init()
+	return decoded, true
+}
+
+//Allocates the maps that gob leaves nil when they were empty
+func (v *CRDTValue) init() {
+	if v.Counter.P == nil {
+		v.Counter.P = make(map[string]int64)
+	}
+	if v.Counter.N == nil {
+		v.Counter.N = make(map[string]int64)
+	}
+	if v.Set.Adds == nil {
+		v.Set.Adds = make(map[string]map[string]bool)
+	}
+	if v.Set.Removes == nil {
+		v.Set.Removes = make(map[string]map[string]bool)
+	}
+	if v.Map == nil {
+		v.Map = make(map[string]*CRDTValue)
+	}
+	for _, field := range v.Map {
+		field.init()
+	}
+}
+
+//Merges another replica's state into this one. Merging is commutative, associative
+//and idempotent, so replicas converge whatever order they see each other's states in
+func (v *CRDTValue) Merge(other *CRDTValue) {
+	if v.Type != other.Type {
+		//Keep the same type on every replica
+		if other.Type < v.Type {
+			*v = *other
+		}
+		return
+	}
+
+	switch v.Type {
+	case CRDT_COUNTER:
+		mergeMax(v.Counter.P, other.Counter.P)
+		mergeMax(v.Counter.N, other.Counter.N)
+	case CRDT_SET:
+		mergeTags(v.Set.Adds, other.Set.Adds)
+		mergeTags(v.Set.Removes, other.Set.Removes)
+	case CRDT_REGISTER:
+		if other.Register.Timestamp > v.Register.Timestamp ||
+			(other.Register.Timestamp == v.Register.Timestamp && other.Register.Writer > v.Register.Writer) {
+			v.Register = other.Register
+		}
+	case CRDT_MAP:
+		for name, field := range other.Map {
+			if mine, found := v.Map[name]; found {
+				mine.Merge(field)
+			} else {
+				v.Map[name] = field
+			}
+		}
+	}
+}
+
+//Keeps the highest count of each node
+func mergeMax(mine map[string]int64, other map[string]int64) {
+	for nodeID, count := range other {
+		if count > mine[nodeID] {
+			mine[nodeID] = count
+		}
+	}
+}
+
+//Unions the tags of each element
+func mergeTags(mine map[string]map[string]bool, other map[string]map[string]bool) {
+	for element, tags := range other {
+		if mine[element] == nil {
+			mine[element] = make(map[string]bool)
+		}
+		for tag := range tags {
+			mine[element][tag] = true
+		}
+	}
+}
+
+//Returns the CRDT an operation applies to: this value, or the named field of this map,
+//created empty if missing
+func (v *CRDTValue) target(field string, crdtType CRDTType) (*CRDTValue, error) {
+	if field == "" {
+		if v.Type != crdtType {
+			return nil, ErrCRDTType
+		}
+		return v, nil
+	}
+	if v.Type != CRDT_MAP {
+		return nil, ErrCRDTType
+	}
+	if v.Map[field] == nil {
+		v.Map[field] = NewCRDT(crdtType)
+	}
+	if v.Map[field].Type != crdtType {
+		return nil, ErrCRDTType
+	}
+	return v.Map[field], nil
+}
+
+//Returns the client-facing view of this CRDT
+func (v *CRDTValue) view() CRDTResult {
+	result := CRDTResult{Type: v.Type}
+	switch v.Type {
+	case CRDT_COUNTER:
+		for _, count := range v.Counter.P {
+			result.Counter += count
+		}
+		for _, count := range v.Counter.N {
+			result.Counter -= count
+		}
+	case CRDT_SET:
+		elements := make([]string, 0)
+		for element, tags := range v.Set.Adds {
+			for tag := range tags {
+				if !v.Set.Removes[element][tag] {
+					elements = append(elements, element)
+					break
+				}
+			}
+		}
+		sort.Strings(elements)
+		for _, element := range elements {
+			result.Set = append(result.Set, []byte(element))
+		}
+	case CRDT_REGISTER:
+		result.Register = v.Register.Value
+	case CRDT_MAP:
+		result.Map = make(map[string]CRDTResult)
+		for name, field := range v.Map {
+			result.Map[name] = field.view()
+		}
+	}
+	return result
+}
+
+//Merges concurrent CRDT versions into a single version
+type crdtResolver struct{}
+
+func (crdtResolver) Resolve(siblings []ObjectEntry) []ObjectEntry {
+	merged, ok := mergeCRDTs(siblings)
+	if !ok || merged == nil {
+		return siblings
+	}
+	return MergeSiblings(siblings, EncodeCRDT(merged))
+}
+
+//Merges the CRDT states of entries. Returns false if an entry is not a CRDT,
+//and a nil state if there are no entries
+func mergeCRDTs(entries []ObjectEntry) (*CRDTValue, bool) {
+	var merged *CRDTValue
+	for _, entry := range entries {
+		state, ok := DecodeCRDT(entry.Value)
+		if !ok {
+			return nil, false
+		}
+		if merged == nil {
+			merged = state
+		} else {
+			merged.Merge(state)
+		}
+	}
+	return merged, true
+}
+
+//Returns true if every entry holds a CRDT
+func allCRDTs(entries []ObjectEntry) bool {
+	for _, entry := range entries {
+		if !bytes.HasPrefix(entry.Value, []byte(CRDT_MAGIC)) {
+			return false
+		}
+	}
+	return true
+}
+
+//Reads a CRDT from R replicas, applies op to the part selected by args and writes
+//the result back to W replicas as a version descending from everything read.
+//Updates coordinated by this node are serialized, so none is lost to a concurrent one
+func (s *DynamoServer) updateCRDT(args CRDTArgs, crdtType CRDTType, op func(v *CRDTValue), result *CRDTResult) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	w, err := writeQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+
+	s.casLock.Lock()
+	defer s.casLock.Unlock()
+	current, _, _, err := s.get(key, r, s.replicas(props))
+	if err != nil {
+		return err
+	}
+	state, ok := mergeCRDTs(current.EntryList)
+	if !ok {
+		return ErrCRDTType
+	}
+	if state == nil {
+		state = NewCRDT(crdtType)
+		if args.Field != "" {
+			state = NewCRDT(CRDT_MAP)
+		}
+	}
+
+	target, err := state.target(args.Field, crdtType)
+	if err != nil {
+		return err
+	}
+	op(target)
+
+	var res PutResult
+	err = s.put(NewPutArgs(key, CombineContexts(current.EntryList), EncodeCRDT(state)), versionInfo{}, w, s.replicas(props), &res)
+	if err != nil {
+		return err
+	}
+	*result = target.view()
+	result.Context = res.Context
+	result.Success = res.Success
+	return nil
+}
+
+//Adds args.Delta, which may be negative, to a counter
+func (s *DynamoServer) Increment(args CRDTArgs, result *CRDTResult) error {
+	return s.updateCRDT(args, CRDT_COUNTER, func(v *CRDTValue) {
+		if args.Delta >= 0 {
+			v.Counter.P[s.nodeID] += args.Delta
+		} else {
+			v.Counter.N[s.nodeID] -= args.Delta
+		}
+	}, result)
+}
+
+//Adds args.Element to a set
+func (s *DynamoServer) AddToSet(args CRDTArgs, result *CRDTResult) error {
+	tag := s.nodeID + ":" + strconv.FormatInt(s.hlc.Now(), 10)
+	return s.updateCRDT(args, CRDT_SET, func(v *CRDTValue) {
+		element := string(args.Element)
+		if v.Set.Adds[element] == nil {
+			v.Set.Adds[element] = make(map[string]bool)
+		}
+		v.Set.Adds[element][tag] = true
+	}, result)
+}
+
+//Removes args.Element from a set. Adds of the element that this node has not yet seen survive
+func (s *DynamoServer) RemoveFromSet(args CRDTArgs, result *CRDTResult) error {
+	return s.updateCRDT(args, CRDT_SET, func(v *CRDTValue) {
+		element := string(args.Element)
+		if v.Set.Removes[element] == nil {
+			v.Set.Removes[element] = make(map[string]bool)
+		}
+		for tag := range v.Set.Adds[element] {
+			v.Set.Removes[element][tag] = true
+		}
+	}, result)
+}
+
+//Sets a register to args.Element
+func (s *DynamoServer) SetRegister(args CRDTArgs, result *CRDTResult) error {
+	timestamp := s.hlc.Now()
+	return s.updateCRDT(args, CRDT_REGISTER, func(v *CRDTValue) {
+		v.Register = LWWRegister{
+			Value:     args.Element,
+			Timestamp: timestamp,
+			Writer:    s.nodeID,
+		}
+	}, result)
+}
+
+//Reads a CRDT from R replicas and returns its merged value
+func (s *DynamoServer) Fetch(args CRDTArgs, result *CRDTResult) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+
+	current, replicas, _, err := s.get(key, r, s.replicas(props))
+	if err != nil {
+		return err
+	}
+	state, ok := mergeCRDTs(current.EntryList)
+	if !ok {
+		return ErrCRDTType
+	}
+	if state != nil && args.Field != "" {
+		if state.Type != CRDT_MAP {
+			return ErrCRDTType
+		}
+		state = state.Map[args.Field]
+	}
+
+	*result = CRDTResult{}
+	if state != nil {
+		*result = state.view()
+	}
+	result.Context = CombineContexts(current.EntryList)
+	result.Success = replicas >= r
+	return nil
+}
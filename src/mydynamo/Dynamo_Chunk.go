@@ -0,0 +1,321 @@
+package mydynamo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/rpc"
+	"time"
+)
+
+//Size of the chunks large values are split into. Values up to this size are stored inline
+const CHUNK_SIZE int = 1 << 20
+
+//Prefix marking a value as the manifest of a chunked value
+const MANIFEST_MAGIC string = "\x00chunks"
+
+var ErrChunkNotFound = errors.New("chunk not found")
+var ErrChunkCorrupted = errors.New("chunk does not match its ID")
+var ErrChunkUpload = errors.New("chunk upload did not reach W replicas")
+var ErrSiblings = errors.New("key has concurrent versions")
+
+//Stored in place of a value split into chunks. The manifest is an ordinary version,
+//carrying the vector clock, while chunks are immutable and shared by every version using them
+type ChunkManifest struct {
+	Size   int64    //Length of the whole value
+	Chunks []string //IDs of the chunks, in order
+}
+
+//Chunks held by a node, by ID, guarded by storeLock. Chunks spilled to disk are in added only
+type chunkStore struct {
+	data  map[string][]byte    //Chunks in memory
+	added map[string]time.Time //Time each chunk was stored, chunks are not collected while recent
+}
+
+//Creates an empty chunk store
+func newChunkStore() *chunkStore {
+	return &chunkStore{
+		data:  make(map[string][]byte),
+		added: make(map[string]time.Time),
+	}
+}
+
+//Returns the ID of a chunk: the hex SHA-256 of its content
+func ChunkID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//Encodes a manifest as a value
+func EncodeManifest(manifest ChunkManifest) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(MANIFEST_MAGIC)
+	err := gob.NewEncoder(&buf).Encode(manifest)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to encode manifest", err)
+	}
+	return buf.Bytes()
+}
+
+//Decodes a value written by EncodeManifest. Returns false if value is not a manifest
+func DecodeManifest(value []byte) (*ChunkManifest, bool) {
+	if !bytes.HasPrefix(value, []byte(MANIFEST_MAGIC)) {
+		return nil, false
+	}
+	var manifest ChunkManifest
+	err := gob.NewDecoder(bytes.NewReader(value[len(MANIFEST_MAGIC):])).Decode(&manifest)
+	if err != nil {
+		return nil, false
+	}
+	return &manifest, true
+}
+
+//Returns true if any entry is a manifest
+func anyManifest(entries []ObjectEntry) bool {
+	for _, entry := range entries {
+		if bytes.HasPrefix(entry.Value, []byte(MANIFEST_MAGIC)) {
+			return true
+		}
+	}
+	return false
+}
+
+//Stores a chunk on this server, do not replicate to other servers. Returns its ID
+func (s *DynamoServer) PutChunkLocal(data []byte, result *string) error {
+	if s.crashed {
+		return errors.New("Crashed")
+	}
+	id := ChunkID(data)
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if _, found := s.chunks.added[id]; !found {
+		size := int64(len(id) + len(data))
+		if err := s.makeRoom(chunkName(id), size); err != nil {
+			log.Println(DYNAMO_SERVER, "rejecting chunk", id, err)
+			return err
+		}
+		s.chunks.data[id] = data
+		s.spill.setSize(chunkName(id), size)
+	}
+	s.chunks.added[id] = time.Now()
+	*result = id
+	return nil
+}
+
+//Gets a chunk from this server, do not contact other servers
+func (s *DynamoServer) GetChunkLocal(id string, result *[]byte) error {
+	if s.crashed {
+		return errors.New("Crashed")
+	}
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if data, found := s.chunks.data[id]; found {
+		s.markUsed(chunkName(id))
+		*result = data
+		return nil
+	}
+	if !s.isSpilled(chunkName(id)) {
+		return ErrChunkNotFound
+	}
+	// spilled chunks are read in place, they are mostly read once per download
+	data, err := ioutil.ReadFile(s.spill.path(chunkName(id)))
+	if err != nil || ChunkID(data) != id {
+		log.Println(DYNAMO_SERVER, "unreadable spilled chunk", id, err)
+		return ErrChunkNotFound
+	}
+	*result = data
+	return nil
+}
+
+//Stores a chunk on this server and W - 1 other replicas of args.Bucket
+func (s *DynamoServer) PutChunk(args ChunkArgs, result *ChunkResult) error {
+	props := s.bucket(args.Bucket)
+	w, err := writeQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	var id string
+	err = s.PutChunkLocal(args.Data, &id)
+	if err != nil {
+		return err
+	}
+
+	cnt := 0
+	for _, node := range s.replicas(props) {
+		if cnt == w-1 {
+			break
+		}
+		if node == s.selfNode {
+			continue
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		if clientInstance.PutChunkLocal(args.Data) == id {
+			cnt++
+		}
+		clientInstance.CleanConn()
+	}
+	*result = ChunkResult{
+		ID:       id,
+		Replicas: cnt + 1,
+		Success:  cnt == w-1,
+	}
+	return nil
+}
+
+//Gets a chunk from this server or, when it does not have it, from the first other node that does
+func (s *DynamoServer) GetChunk(id string, result *[]byte) error {
+	err := s.GetChunkLocal(id, result)
+	if err != ErrChunkNotFound {
+		return err
+	}
+	for _, node := range s.preferenceList {
+		if node == s.selfNode {
+			continue
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		data := clientInstance.GetChunkLocal(id)
+		clientInstance.CleanConn()
+		if data != nil && ChunkID(data) == id {
+			*result = data
+			return nil
+		}
+	}
+	return ErrChunkNotFound
+}
+
+//Returns the IDs of the chunks referenced by the manifests in storage
+func (s *DynamoServer) referencedChunks() map[string]bool {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	referenced := make(map[string]bool)
+	for _, key := range s.storedKeys() {
+		for _, entry := range s.peek(key) {
+			entry, err := decompressEntry(entry)
+			if err != nil {
+				continue
+			}
+			if manifest, ok := DecodeManifest(entry.Value); ok {
+				for _, id := range manifest.Chunks {
+					referenced[id] = true
+				}
+			}
+		}
+	}
+	return referenced
+}
+
+//Deletes the chunks of this server that no stored manifest references and that were
+//stored at least olderThan seconds ago, leaving uploads in progress alone.
+//Returns the number of chunks deleted
+func (s *DynamoServer) CollectChunks(olderThan int, result *int) error {
+	referenced := s.referencedChunks()
+	deadline := time.Now().Add(-time.Duration(olderThan) * time.Second)
+
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	collected := 0
+	for id, added := range s.chunks.added {
+		if !referenced[id] && !added.After(deadline) {
+			delete(s.chunks.data, id)
+			delete(s.chunks.added, id)
+			s.spill.setSize(chunkName(id), 0)
+			s.unspill(chunkName(id))
+			collected++
+		}
+	}
+	if collected > 0 {
+		log.Println(DYNAMO_SERVER, "collected", collected, "unreferenced chunks")
+	}
+	*result = collected
+	return nil
+}
+
+//Puts the value read from value, splitting it into chunks of CHUNK_SIZE bytes when it is
+//larger than that. Each chunk is uploaded in its own call and the manifest listing them
+//is then written with args, so neither side holds the whole value at once
+func (dynamoClient *RPCClient) PutStream(args PutOptions, value io.Reader) (*PutResult, error) {
+	reader := bufio.NewReader(value)
+	buf := make([]byte, CHUNK_SIZE)
+	manifest := ChunkManifest{Chunks: make([]string, 0)}
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if len(manifest.Chunks) == 0 {
+			// small values are stored inline
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				args.Value = append([]byte{}, buf[:n]...)
+				break
+			}
+		}
+		if n > 0 {
+			res := dynamoClient.PutChunk(ChunkArgs{Bucket: args.Bucket, Data: buf[:n], Consistency: args.Consistency})
+			if res == nil || !res.Success {
+				return nil, ErrChunkUpload
+			}
+			manifest.Chunks = append(manifest.Chunks, res.ID)
+			manifest.Size += int64(n)
+		}
+		if n < len(buf) {
+			args.Value = EncodeManifest(manifest)
+			break
+		}
+	}
+
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	var result PutResult
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutWithOptions", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	return &result, nil
+}
+
+//Gets a value and writes it to value, fetching the chunks of a chunked value one at a time.
+//Returns ErrSiblings without writing anything when the key has concurrent versions:
+//use DecodeManifest and GetChunk on each of result.EntryList instead
+func (dynamoClient *RPCClient) GetStream(args GetArgs, value io.Writer) (*GetResult, error) {
+	result, err := dynamoClient.GetV2(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.EntryList) == 0 {
+		return result, nil
+	}
+	if len(result.EntryList) > 1 {
+		return result, ErrSiblings
+	}
+
+	manifest, ok := DecodeManifest(result.EntryList[0].Value)
+	if !ok {
+		_, err = value.Write(result.EntryList[0].Value)
+		return result, err
+	}
+	for _, id := range manifest.Chunks {
+		data, err := dynamoClient.GetChunk(id)
+		if err != nil {
+			return result, err
+		}
+		if _, err = value.Write(data); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package mydynamo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+//Version of the binary VectorClock encoding, stored as the first byte of every
+//marshaled clock and of every clock stream
+const CLOCK_ENCODING_VERSION byte = 1
+
+var ErrInvalidClockEncoding = errors.New("invalid vector clock encoding")
+
+//Encodes this VectorClock in a compact binary form. gob uses it whenever clocks are sent
+//between nodes. The encoding is a version byte, a uvarint entry count, then for each node
+//(sorted by ID) a node reference, its version and the time it was last incremented as
+//zigzag varints; followed by Timestamp, a reference to Writer, a reference to the dot's node
+//and the dot's counter. A node reference is the uvarint index+1 of a node ID seen earlier
+//in the same encoding, or 0 followed by a uvarint-prefixed ID seen for the first time.
+//Each marshaled clock stands alone, so node IDs are only shared within a clock: use a
+//VectorClockEncoder to intern them across many clocks. Context tokens reuse this encoding
+func (s VectorClock) MarshalBinary() ([]byte, error) {
+	var table nodeTable
+	buf := []byte{CLOCK_ENCODING_VERSION}
+	return table.appendClock(buf, s), nil
+}
+
+//Decodes a VectorClock produced by MarshalBinary
+func (s *VectorClock) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 || data[0] != CLOCK_ENCODING_VERSION {
+		return ErrInvalidClockEncoding
+	}
+	var table nodeTable
+	clock, rest, err := table.decodeClock(data[1:])
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return ErrInvalidClockEncoding
+	}
+	*s = clock
+	return nil
+}
+
+//Writes a stream of VectorClocks, e.g. to a storage file. Node IDs are interned once per
+//stream, so a clock mostly costs its versions and timestamps
+type VectorClockEncoder struct {
+	w       io.Writer
+	table   nodeTable
+	started bool
+}
+
+//Creates an encoder writing to w
+func NewVectorClockEncoder(w io.Writer) *VectorClockEncoder {
+	return &VectorClockEncoder{w: w}
+}
+
+//Appends clock to the stream as a uvarint-prefixed record
+func (e *VectorClockEncoder) Encode(clock VectorClock) error {
+	var buf []byte
+	if !e.started {
+		buf = append(buf, CLOCK_ENCODING_VERSION)
+	}
+	record := e.table.appendClock(nil, clock)
+	buf = binary.AppendUvarint(buf, uint64(len(record)))
+	buf = append(buf, record...)
+	if _, err := e.w.Write(buf); err != nil {
+		return err
+	}
+	e.started = true
+	return nil
+}
+
+//Reads a stream of VectorClocks written by a VectorClockEncoder
+type VectorClockDecoder struct {
+	r       *bufio.Reader
+	table   nodeTable
+	started bool
+}
+
+//Creates a decoder reading from r
+func NewVectorClockDecoder(r io.Reader) *VectorClockDecoder {
+	return &VectorClockDecoder{r: bufio.NewReader(r)}
+}
+
+//Reads the next clock of the stream. Returns io.EOF at the end of the stream
+func (d *VectorClockDecoder) Decode(clock *VectorClock) error {
+	if !d.started {
+		version, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if version != CLOCK_ENCODING_VERSION {
+			return ErrInvalidClockEncoding
+		}
+		d.started = true
+	}
+
+	size, err := binary.ReadUvarint(d.r)
+	if err == io.EOF {
+		return io.EOF
+	} else if err != nil {
+		return ErrInvalidClockEncoding
+	}
+	record, err := io.ReadAll(io.LimitReader(d.r, int64(min(size, math.MaxInt32))))
+	if err != nil || uint64(len(record)) != size {
+		return ErrInvalidClockEncoding
+	}
+
+	decoded, rest, err := d.table.decodeClock(record)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return ErrInvalidClockEncoding
+	}
+	*clock = decoded
+	return nil
+}
+
+//Node IDs interned by an encoding, in order of first use
+type nodeTable struct {
+	nodeIDs []string
+	indexes map[string]uint64
+}
+
+//Appends the encoding of clock to buf, interning its node IDs
+func (t *nodeTable) appendClock(buf []byte, clock VectorClock) []byte {
+	nodeIDs := make([]string, 0, len(clock.VectorClock))
+	for nodeID := range clock.VectorClock {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	buf = binary.AppendUvarint(buf, uint64(len(nodeIDs)))
+	for _, nodeID := range nodeIDs {
+		buf = t.appendNodeID(buf, nodeID)
+		buf = binary.AppendVarint(buf, int64(clock.VectorClock[nodeID]))
+		buf = binary.AppendVarint(buf, clock.Timestamps[nodeID])
+	}
+	buf = binary.AppendVarint(buf, clock.Timestamp)
+	buf = t.appendNodeID(buf, clock.Writer)
+	buf = t.appendNodeID(buf, clock.Dot.NodeID)
+	buf = binary.AppendVarint(buf, int64(clock.Dot.Counter))
+	return buf
+}
+
+//Appends a reference to nodeID, or nodeID itself the first time it is seen
+func (t *nodeTable) appendNodeID(buf []byte, nodeID string) []byte {
+	if index, ok := t.indexes[nodeID]; ok {
+		return binary.AppendUvarint(buf, index+1)
+	}
+	if t.indexes == nil {
+		t.indexes = make(map[string]uint64)
+	}
+	t.indexes[nodeID] = uint64(len(t.nodeIDs))
+	t.nodeIDs = append(t.nodeIDs, nodeID)
+
+	buf = binary.AppendUvarint(buf, 0)
+	buf = binary.AppendUvarint(buf, uint64(len(nodeID)))
+	return append(buf, nodeID...)
+}
+
+//Decodes a clock from the start of data, returning the remaining bytes.
+//Timestamps of zero are left out of the decoded Timestamps map
+func (t *nodeTable) decodeClock(data []byte) (VectorClock, []byte, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return VectorClock{}, nil, ErrInvalidClockEncoding
+	}
+	data = data[n:]
+
+	var err error
+	clock := NewVectorClock()
+	for i := uint64(0); i < count; i++ {
+		var nodeID string
+		var version, updated int64
+		if nodeID, data, err = t.decodeNodeID(data); err != nil {
+			return VectorClock{}, nil, err
+		}
+		if version, data, err = decodeVarint(data); err != nil {
+			return VectorClock{}, nil, err
+		}
+		if updated, data, err = decodeVarint(data); err != nil {
+			return VectorClock{}, nil, err
+		}
+		if _, ok := clock.VectorClock[nodeID]; ok {
+			return VectorClock{}, nil, ErrInvalidClockEncoding
+		}
+		clock.VectorClock[nodeID] = int(version)
+		if updated != 0 {
+			clock.Timestamps[nodeID] = updated
+		}
+	}
+
+	var counter int64
+	if clock.Timestamp, data, err = decodeVarint(data); err != nil {
+		return VectorClock{}, nil, err
+	}
+	if clock.Writer, data, err = t.decodeNodeID(data); err != nil {
+		return VectorClock{}, nil, err
+	}
+	if clock.Dot.NodeID, data, err = t.decodeNodeID(data); err != nil {
+		return VectorClock{}, nil, err
+	}
+	if counter, data, err = decodeVarint(data); err != nil {
+		return VectorClock{}, nil, err
+	}
+	clock.Dot.Counter = int(counter)
+	return clock, data, nil
+}
+
+//Decodes a node reference from the start of data, interning new node IDs
+func (t *nodeTable) decodeNodeID(data []byte) (string, []byte, error) {
+	ref, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", nil, ErrInvalidClockEncoding
+	}
+	data = data[n:]
+	if ref > 0 {
+		if ref > uint64(len(t.nodeIDs)) {
+			return "", nil, ErrInvalidClockEncoding
+		}
+		return t.nodeIDs[ref-1], data, nil
+	}
+
+	idLen, n := binary.Uvarint(data)
+	if n <= 0 || idLen > uint64(len(data)-n) {
+		return "", nil, ErrInvalidClockEncoding
+	}
+	nodeID := string(data[n : n+int(idLen)])
+	if _, ok := t.indexes[nodeID]; ok {
+		return "", nil, ErrInvalidClockEncoding
+	}
+	if t.indexes == nil {
+		t.indexes = make(map[string]uint64)
+	}
+	t.indexes[nodeID] = uint64(len(t.nodeIDs))
+	t.nodeIDs = append(t.nodeIDs, nodeID)
+	return nodeID, data[n+int(idLen):], nil
+}
+
+//Decodes a zigzag varint from the start of data
+func decodeVarint(data []byte) (int64, []byte, error) {
+	value, n := binary.Varint(data)
+	if n <= 0 {
+		return 0, nil, ErrInvalidClockEncoding
+	}
+	return value, data[n:], nil
+}
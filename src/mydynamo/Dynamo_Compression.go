@@ -0,0 +1,182 @@
+package mydynamo
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"sync/atomic"
+)
+
+//Codecs values can be stored and sent between nodes with, recorded on each entry
+const CODEC_NONE string = ""       //Value stored as is
+const CODEC_GZIP string = "gzip"   //Value compressed with gzip
+const CODEC_FLATE string = "flate" //Value compressed with raw DEFLATE
+
+//Largest value a compressed value may decompress to, so that a small payload cannot
+//expand into more memory than any legitimate value would take
+const MAX_DECOMPRESSED_SIZE int64 = 256 << 20
+
+var ErrUnknownCodec = errors.New("unknown compression codec")
+var ErrDecompressedTooLarge = errors.New("compressed value expands past the maximum value size")
+
+//Returns true if codec is one of the supported codecs
+func validCodec(codec string) bool {
+	return codec == CODEC_NONE || codec == CODEC_GZIP || codec == CODEC_FLATE
+}
+
+//Compresses value with codec
+func compressValue(codec string, value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch codec {
+	case CODEC_NONE:
+		return value, nil
+	case CODEC_GZIP:
+		writer = gzip.NewWriter(&buf)
+	case CODEC_FLATE:
+		writer, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return nil, ErrUnknownCodec
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(value); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//Decompresses a value compressed with codec
+func decompressValue(codec string, value []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch codec {
+	case CODEC_NONE:
+		return value, nil
+	case CODEC_GZIP:
+		reader, err = gzip.NewReader(bytes.NewReader(value))
+	case CODEC_FLATE:
+		reader = flate.NewReader(bytes.NewReader(value))
+	default:
+		return nil, ErrUnknownCodec
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// read one byte past the limit to tell a value of exactly the limit from a larger one
+	plain, err := ioutil.ReadAll(io.LimitReader(reader, MAX_DECOMPRESSED_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(plain)) > MAX_DECOMPRESSED_SIZE {
+		return nil, ErrDecompressedTooLarge
+	}
+	return plain, nil
+}
+
+//Returns the plain version of a stored entry.
+//Fails with ErrChecksumMismatch if the value does not match the checksum it came with
+func decompressEntry(entry StoredEntry) (ObjectEntry, error) {
+	if !validEntry(entry) {
+		return ObjectEntry{}, ErrChecksumMismatch
+	}
+	value, err := decompressValue(entry.Codec, entry.Value)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	return ObjectEntry{Context: entry.Context, Value: value}, nil
+}
+
+//Returns the plain versions of stored entries
+func decompressEntries(entries []StoredEntry) ([]ObjectEntry, error) {
+	result := make([]ObjectEntry, 0, len(entries))
+	for _, entry := range entries {
+		plain, err := decompressEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, plain)
+	}
+	return result, nil
+}
+
+//Returns the versions of stored entries without their values, for bookkeeping done by clock
+func clocksOf(entries []StoredEntry) []ObjectEntry {
+	result := make([]ObjectEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, ObjectEntry{Context: entry.Context})
+	}
+	return result
+}
+
+//Compresses a plain entry with codec, keeping it as is when that does not make it smaller.
+//Either way the entry returned carries the checksum of its value
+func (s *DynamoServer) compressEntry(entry ObjectEntry, codec string) StoredEntry {
+	stored := withChecksum(StoredEntry{Context: entry.Context, Value: entry.Value})
+	if codec == CODEC_NONE {
+		return stored
+	}
+	compressed, err := compressValue(codec, entry.Value)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to compress value", err)
+		return stored
+	}
+	atomic.AddInt64(&s.counters.compressionInput, int64(len(entry.Value)))
+	if len(compressed) >= len(entry.Value) {
+		atomic.AddInt64(&s.counters.compressionOutput, int64(len(entry.Value)))
+		return stored
+	}
+	atomic.AddInt64(&s.counters.compressionOutput, int64(len(compressed)))
+	return withChecksum(StoredEntry{Context: entry.Context, Value: compressed, Codec: codec})
+}
+
+//Compresses the value of a plain write with the codec of its bucket, before it is sent to the replicas
+func (s *DynamoServer) compressPut(args IndexedPutArgs) IndexedPutArgs {
+	bucket, _ := splitStorageKey(args.Value.Key)
+	entry := s.compressEntry(ObjectEntry{Value: args.Value.Value}, s.bucket(bucket).Compression)
+	args.Value.Value = entry.Value
+	args.Codec = entry.Codec
+	args.Checksum = entry.Checksum
+	return args
+}
+
+//Returns the versions of key to store: those already encoded, by versionID, are kept
+//as they are so that data written under another codec stays readable; the others are
+//compressed with the codec of the key's bucket and checksummed
+func (s *DynamoServer) encodeEntries(key string, entries []ObjectEntry, encoded map[string]StoredEntry) []StoredEntry {
+	bucket, _ := splitStorageKey(key)
+	codec := s.bucket(bucket).Compression
+	result := make([]StoredEntry, 0, len(entries))
+	for _, entry := range entries {
+		if stored, found := encoded[versionID(entry.Context.Clock)]; found {
+			result = append(result, stored)
+			continue
+		}
+		result = append(result, s.compressEntry(entry, codec))
+	}
+	return result
+}
+
+//Returns a result read from another node with its values decompressed,
+//nil if it is nil or holds a value that cannot be decompressed
+func plainResult(result *StoredResult) *DynamoResult {
+	if result == nil {
+		return nil
+	}
+	entries, err := decompressEntries(result.EntryList)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to decompress value", err)
+		return nil
+	}
+	return &DynamoResult{EntryList: entries}
+}
@@ -0,0 +1,52 @@
+package mydynamo
+
+import "errors"
+
+var ErrConflict = errors.New("context does not match the stored versions")
+
+//Writes a value only if the versions currently stored across the write quorum are
+//exactly the ones described by args.Context. On a mismatch nothing is written,
+//Conflict is set and Current holds the stored versions so the caller can retry
+func (s *DynamoServer) ConditionalPut(args PutOptions, result *ConditionalPutResult) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	w, err := writeQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	if err := validateTerms(args.Indexes); err != nil {
+		return err
+	}
+
+	// conditional writes through this coordinator are applied one at a time
+	s.casLock.Lock()
+	defer s.casLock.Unlock()
+
+	current, replicas, _, err := s.get(key, w, s.replicas(props))
+	if err != nil {
+		return err
+	}
+	if replicas < w {
+		return ErrQuorumNotMet
+	}
+
+	stored := CombineContexts(current.EntryList)
+	if !stored.Clock.Equals(args.Context.Clock) {
+		*result = ConditionalPutResult{
+			Conflict: true,
+			Current:  current.EntryList,
+		}
+		return nil
+	}
+
+	var res PutResult
+	err = s.put(NewPutArgs(key, args.Context, args.Value), optionsInfo(args), w, s.replicas(props), &res)
+	if err != nil {
+		return err
+	}
+	*result = ConditionalPutResult{Result: res}
+	return nil
+}
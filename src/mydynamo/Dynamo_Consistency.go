@@ -0,0 +1,57 @@
+package mydynamo
+
+import "errors"
+
+//How many replicas must answer a read or acknowledge a write
+type ConsistencyLevel int
+
+const (
+	CONSISTENCY_DEFAULT  ConsistencyLevel = iota //Use the node's r_value / w_value
+	CONSISTENCY_ONE                              //Only the coordinator
+	CONSISTENCY_QUORUM                           //A majority of the N replicas
+	CONSISTENCY_ALL                              //All N replicas
+	CONSISTENCY_EXPLICIT                         //The R or W given in Consistency
+)
+
+var ErrInvalidConsistency = errors.New("invalid consistency level")
+
+//Consistency requested for a single Get or Put. The zero value uses the node defaults
+type Consistency struct {
+	Level ConsistencyLevel
+	R     int //Replicas to read from when Level is CONSISTENCY_EXPLICIT
+	W     int //Replicas to write to when Level is CONSISTENCY_EXPLICIT
+}
+
+//Returns the number of replicas, including this node, that must answer a read in the given bucket
+func readQuorum(c Consistency, props BucketProps) (int, error) {
+	return quorum(c, props.R, c.R, props.N)
+}
+
+//Returns the number of replicas, including this node, that must acknowledge a write in the given bucket
+func writeQuorum(c Consistency, props BucketProps) (int, error) {
+	return quorum(c, props.W, c.W, props.N)
+}
+
+//Resolves a consistency level against n, the number of replicas of a key
+func quorum(c Consistency, defaultValue int, explicit int, n int) (int, error) {
+	var value int
+	switch c.Level {
+	case CONSISTENCY_DEFAULT:
+		return defaultValue, nil
+	case CONSISTENCY_ONE:
+		value = 1
+	case CONSISTENCY_QUORUM:
+		value = n/2 + 1
+	case CONSISTENCY_ALL:
+		value = n
+	case CONSISTENCY_EXPLICIT:
+		value = explicit
+	default:
+		return 0, ErrInvalidConsistency
+	}
+
+	if value < 1 || value > n {
+		return 0, ErrInvalidConsistency
+	}
+	return value, nil
+}
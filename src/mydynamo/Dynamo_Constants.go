@@ -20,3 +20,19 @@ const SERVER_PORT string = "starting_port"
 const W_VALUE string = "w_value"
 const R_VALUE string = "r_value"
 const CLUSTER_SIZE string = "cluster_size"
+const JSON_RPC_PORT string = "json_rpc_starting_port"
+const HTTP_PORT string = "http_starting_port"
+const CONTEXT_HMAC_KEY string = "context_hmac_key"
+
+//Config file sections named BUCKET_SECTION_PREFIX + name define buckets
+const BUCKET_SECTION_PREFIX string = "bucket."
+const N_VALUE string = "n_value"
+const CONFLICT_MODE string = "conflict_mode"
+const TTL string = "ttl"
+const CAUSALITY string = "causality"
+const COMPRESSION string = "compression"
+const CLOCK_PRUNE_THRESHOLD string = "clock_prune_threshold"
+const SCRUB_INTERVAL string = "scrub_interval"
+const MEMORY_BUDGET string = "memory_budget"
+const SPILL_DIR string = "spill_dir"
+const DISK_BUDGET string = "disk_budget"
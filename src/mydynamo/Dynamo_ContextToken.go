@@ -0,0 +1,90 @@
+package mydynamo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+//Version of the binary context encoding, stored as the first byte of every token.
+//Version 1 tokens had an encoding of their own and are no longer accepted
+const CONTEXT_TOKEN_VERSION byte = 2
+
+//Flag set in the second byte of a token when it ends with an HMAC
+const CONTEXT_TOKEN_SIGNED byte = 1
+
+//Number of HMAC-SHA256 bytes kept at the end of a signed token
+const CONTEXT_TOKEN_MAC_SIZE int = 16
+
+var ErrInvalidContextToken = errors.New("invalid context token")
+var ErrContextTampered = errors.New("context token signature mismatch")
+
+//Encodes a Context in the compact binary form used by context tokens: version byte,
+//flags byte, then the versions of its clock and the times they were last updated, which
+//clock pruning relies on, in the encoding of VectorClock.MarshalBinary.
+//A dotted version vector is encoded with its dot folded into the causal context
+func EncodeContext(context Context) []byte {
+	full := context.Clock.full()
+	causal := NewVectorClock()
+	causal.VectorClock = full.VectorClock
+	causal.Timestamps = full.Timestamps
+	var table nodeTable
+	return table.appendClock([]byte{CONTEXT_TOKEN_VERSION, 0}, causal)
+}
+
+//Decodes a Context produced by EncodeContext
+func DecodeContext(data []byte) (Context, error) {
+	if len(data) < 2 || data[0] != CONTEXT_TOKEN_VERSION {
+		return Context{}, ErrInvalidContextToken
+	}
+	var table nodeTable
+	clock, rest, err := table.decodeClock(data[2:])
+	if err != nil || len(rest) != 0 {
+		return Context{}, ErrInvalidContextToken
+	}
+	return NewContext(clock), nil
+}
+
+//Encodes a Context as an opaque, URL-safe token. When key is non-empty the token
+//is signed with HMAC-SHA256 so that servers sharing the key can reject tampered contexts
+func EncodeContextToken(context Context, key []byte) string {
+	buf := EncodeContext(context)
+	if len(key) > 0 {
+		buf[1] |= CONTEXT_TOKEN_SIGNED
+		buf = append(buf, contextMAC(buf, key)...)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+//Decodes a token produced by EncodeContextToken. When key is non-empty the token
+//must carry a valid signature made with the same key
+func DecodeContextToken(token string, key []byte) (Context, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) < 2 {
+		return Context{}, ErrInvalidContextToken
+	}
+
+	if buf[1]&CONTEXT_TOKEN_SIGNED != 0 {
+		if len(buf) < 2+CONTEXT_TOKEN_MAC_SIZE {
+			return Context{}, ErrInvalidContextToken
+		}
+		payload := buf[:len(buf)-CONTEXT_TOKEN_MAC_SIZE]
+		mac := buf[len(buf)-CONTEXT_TOKEN_MAC_SIZE:]
+		if len(key) > 0 && !hmac.Equal(mac, contextMAC(payload, key)) {
+			return Context{}, ErrContextTampered
+		}
+		buf = payload
+	} else if len(key) > 0 {
+		return Context{}, ErrContextTampered
+	}
+
+	return DecodeContext(buf)
+}
+
+//Computes the truncated HMAC-SHA256 of an encoded context
+func contextMAC(payload []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:CONTEXT_TOKEN_MAC_SIZE]
+}
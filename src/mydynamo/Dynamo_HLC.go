@@ -0,0 +1,74 @@
+package mydynamo
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//Number of low bits of a hybrid timestamp holding the logical counter
+const HLC_LOGICAL_BITS uint = 16
+
+//How far ahead of physical time a timestamp received from another node may be
+const HLC_MAX_DRIFT time.Duration = time.Minute
+
+var ErrClockDrift = errors.New("timestamp is too far ahead of the local clock")
+
+//Hybrid logical clock. Timestamps pack the physical time in milliseconds in the high
+//bits and a logical counter in the low HLC_LOGICAL_BITS bits, so they compare as int64s,
+//never go backwards on a node and always exceed every timestamp the node has received
+type HybridClock struct {
+	lock *sync.Mutex
+	last int64
+}
+
+//Creates a new HybridClock
+func NewHybridClock() *HybridClock {
+	return &HybridClock{
+		lock: new(sync.Mutex),
+	}
+}
+
+//Returns a timestamp for a local write
+func (c *HybridClock) Now() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	physical := time.Now().UnixNano() / int64(time.Millisecond) << HLC_LOGICAL_BITS
+	if physical > c.last {
+		c.last = physical
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+//Advances the clock past a timestamp received from another node.
+//Timestamps rejected by checkDrift are ignored
+func (c *HybridClock) Update(remote int64) {
+	if checkDrift(remote) != nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if remote > c.last {
+		c.last = remote
+	}
+}
+
+//Returns ErrClockDrift if a timestamp received from another node is more than HLC_MAX_DRIFT
+//ahead of physical time, as sent by a node whose clock is broken. Following it would stamp
+//every later write of this node with that time too
+func checkDrift(remote int64) error {
+	if HLCTime(remote).After(time.Now().Add(HLC_MAX_DRIFT)) {
+		return ErrClockDrift
+	}
+	return nil
+}
+
+//Returns the physical part of a hybrid timestamp
+func HLCTime(timestamp int64) time.Time {
+	millis := timestamp >> HLC_LOGICAL_BITS
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
@@ -0,0 +1,151 @@
+package mydynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//Header carrying the context token of the versions a client has read
+const CONTEXT_HEADER string = "X-Dynamo-Context"
+
+//Path prefix of the key/value resource
+const KV_PATH string = "/kv/"
+
+//A single sibling as returned by the REST gateway
+type RESTEntry struct {
+	Context string //Context token of this version, see EncodeContextToken
+	Value   []byte //Value of this version, base64 encoded in JSON
+	Deleted bool   //True if this version is a tombstone written by DELETE
+}
+
+//Body of a GET /kv/{key} response
+type RESTResult struct {
+	Key      string
+	Context  string //Context token covering every sibling, to be sent back on the next PUT
+	Siblings []RESTEntry
+}
+
+//Serves the REST gateway for the given server on l
+func ServeREST(l net.Listener, s *DynamoServer) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(KV_PATH, s.handleKV)
+	return http.Serve(l, mux)
+}
+
+//Dispatches requests on /kv/{key} to the coordinator logic of this server
+func (s *DynamoServer) handleKV(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, KV_PATH)
+	if key == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleRESTGet(w, key)
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.handleRESTPut(w, r, PutOptions{Key: key, Value: body})
+	case http.MethodDelete:
+		//Deletes are writes of a tombstone that supersedes the given context
+		s.handleRESTPut(w, r, PutOptions{Key: key, Value: []byte{}, Delete: true})
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+//Reads all siblings of key; answers 300 Multiple Choices when there is more than one
+func (s *DynamoServer) handleRESTGet(w http.ResponseWriter, key string) {
+	var result GetResultV3
+	err := s.GetV3(GetArgs{Key: key}, &result)
+	if err == nil && !result.QuorumMet {
+		err = ErrQuorumNotMet
+	}
+	if err != nil {
+		http.Error(w, err.Error(), restStatus(err))
+		return
+	}
+
+	restResult := RESTResult{
+		Key:      key,
+		Siblings: make([]RESTEntry, 0, len(result.EntryList)),
+	}
+	live := 0
+	for _, entry := range result.EntryList {
+		deleted := entry.Metadata.Deleted
+		if !deleted {
+			live++
+		}
+		restResult.Siblings = append(restResult.Siblings, RESTEntry{
+			Context: EncodeContextToken(entry.Context, s.contextKey),
+			Value:   entry.Value,
+			Deleted: deleted,
+		})
+	}
+	restResult.Context = EncodeContextToken(result.Context, s.contextKey)
+
+	status := http.StatusOK
+	if live == 0 {
+		status = http.StatusNotFound
+	} else if len(restResult.Siblings) > 1 {
+		status = http.StatusMultipleChoices
+	}
+	writeJSON(w, status, restResult)
+}
+
+//Writes args, descending from the context sent in CONTEXT_HEADER (if any)
+func (s *DynamoServer) handleRESTPut(w http.ResponseWriter, r *http.Request, args PutOptions) {
+	args.Context = NewContext(NewVectorClock())
+	if header := r.Header.Get(CONTEXT_HEADER); header != "" {
+		var err error
+		args.Context, err = DecodeContextToken(header, s.contextKey)
+		if err != nil {
+			http.Error(w, "invalid context: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result PutResult
+	err := s.PutWithOptions(args, &result)
+	if err != nil {
+		http.Error(w, err.Error(), restStatus(err))
+		return
+	}
+	if !result.Success {
+		http.Error(w, "write quorum not reached", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//Returns the status of a response to a request that failed with err
+func restStatus(err error) int {
+	switch err {
+	case ErrInvalidKey, ErrInvalidBucket, ErrInvalidConsistency, ErrInvalidIndexTerm:
+		return http.StatusBadRequest
+	case ErrStorageFull:
+		return http.StatusInsufficientStorage
+	case ErrQuorumNotMet:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+//Writes v as a JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to write response", err)
+	}
+}
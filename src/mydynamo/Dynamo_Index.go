@@ -0,0 +1,161 @@
+package mydynamo
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidIndexTerm = errors.New("invalid index term")
+
+//Returns an error unless every term names an index and neither names nor string terms
+//contain the separator of index entries
+func validateTerms(terms []IndexTerm) error {
+	for _, term := range terms {
+		if term.Index == "" || strings.Contains(term.Index, "\x00") || strings.Contains(term.Value, "\x00") {
+			return ErrInvalidIndexTerm
+		}
+	}
+	return nil
+}
+
+//Returns the start of the index entries of term. Integers are stored big-endian with
+//the sign bit flipped so that entries sort in numeric order
+func termPrefix(index string, term IndexTerm) string {
+	if term.Integer {
+		encoded := make([]byte, 8)
+		binary.BigEndian.PutUint64(encoded, uint64(term.Int)^(1<<63))
+		return index + "\x00i" + string(encoded)
+	}
+	return index + "\x00s" + term.Value + "\x00"
+}
+
+//Returns the entry of the local index recording that key has term
+func indexEntry(term IndexTerm, key string) string {
+	return termPrefix(term.Index, term) + key
+}
+
+//Returns the key of an index entry of index
+func entryKey(index string, entry string, integer bool) string {
+	rest := entry[len(index)+2:]
+	if integer {
+		return rest[8:]
+	}
+	return rest[strings.Index(rest, "\x00")+1:]
+}
+
+//Brings the local index of key up to date with the index terms of its current versions
+func (s *DynamoServer) reindex(key string, current map[string]versionInfo) {
+	entries := make(map[string]bool)
+	bucket, clientKey := splitStorageKey(key)
+	for _, info := range current {
+		for _, term := range info.indexes {
+			entries[indexEntry(term, clientKey)] = true
+		}
+	}
+
+	for _, entry := range s.keyTerms[key] {
+		if !entries[entry] {
+			s.postings.remove(storageKey(bucket, entry))
+		}
+	}
+	indexed := make([]string, 0, len(entries))
+	for entry := range entries {
+		s.postings.insert(storageKey(bucket, entry))
+		indexed = append(indexed, entry)
+	}
+
+	if len(indexed) == 0 {
+		delete(s.keyTerms, key)
+		return
+	}
+	s.keyTerms[key] = indexed
+}
+
+//Returns the keys of this server matching a query, do not contact other servers
+func (s *DynamoServer) IndexQueryLocal(args IndexQueryArgs, result *[]string) error {
+	if s.crashed {
+		*result = nil
+		return errors.New("Crashed")
+	}
+	if err := validateTerms([]IndexTerm{{Index: args.Index, Value: args.Value}}); err != nil {
+		return err
+	}
+
+	var start, end string
+	if args.Integer {
+		start = termPrefix(args.Index, IndexTerm{Int: args.Min, Integer: true})
+		end = prefixEnd(termPrefix(args.Index, IndexTerm{Int: args.Max, Integer: true}))
+	} else {
+		start = termPrefix(args.Index, IndexTerm{Value: args.Value})
+		end = prefixEnd(start)
+	}
+
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for {
+		page := s.postings.scan(args.Bucket, start, end, SCAN_DEFAULT_LIMIT)
+		for _, entry := range page {
+			key := entryKey(args.Index, entry, args.Integer)
+			if !seen[key] && !s.checkExpiry(storageKey(args.Bucket, key)) {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if len(page) < SCAN_DEFAULT_LIMIT {
+			break
+		}
+		start = page[len(page)-1] + "\x00"
+	}
+	*result = keys
+	return nil
+}
+
+//Returns the keys of a bucket indexed under a string term, or under an integer term in
+//[args.Min, args.Max], on any node of the cluster. Every node is queried and keys held
+//by several replicas are returned once, in order
+func (s *DynamoServer) IndexQuery(args IndexQueryArgs, result *IndexQueryResult) error {
+	*result = IndexQueryResult{}
+	var local []string
+	err := s.IndexQueryLocal(args, &local)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	keys := local
+	for _, key := range local {
+		seen[key] = true
+	}
+	nodes := 1
+	for _, node := range s.preferenceList {
+		if node == s.selfNode {
+			continue
+		}
+
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			result.Unreachable = append(result.Unreachable, node)
+			continue
+		}
+		other := clientInstance.IndexQueryLocal(args)
+		clientInstance.CleanConn()
+		if other == nil {
+			result.Unreachable = append(result.Unreachable, node)
+			continue
+		}
+		nodes++
+		for _, key := range other {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	result.Keys = keys
+	result.Nodes = nodes
+	return nil
+}
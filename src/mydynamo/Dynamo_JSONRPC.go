@@ -0,0 +1,24 @@
+package mydynamo
+
+import (
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+//Serves the MyDynamo.* methods registered on rpcServer as JSON-RPC 1.0 over raw TCP.
+//Each request is a single JSON object of the form
+//	{"method": "MyDynamo.Put", "params": [<argument>], "id": <id>}
+//Arguments and results use the standard encoding/json form of the types in Dynamo_Types.go:
+//byte slices (Value) are base64 strings and a VectorClock is {"VectorClock": {"<nodeID>": <version>}}
+func ServeJSONRPC(l net.Listener, rpcServer *rpc.Server) error {
+	for {
+		conn, e := l.Accept()
+		if e != nil {
+			log.Println(DYNAMO_SERVER, "JSON-RPC listener stopped:", e)
+			return e
+		}
+		go rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
@@ -0,0 +1,243 @@
+package mydynamo
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+)
+
+//Returns the names of the buckets with keys in the index, in listing order
+func (index *keyIndex) bucketNames() []string {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	names := make([]string, 0, len(index.buckets))
+	for name := range index.buckets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return bucketBefore(names[i], names[j])
+	})
+	return names
+}
+
+//Returns true if the keys of bucket a are listed before those of bucket b:
+//named buckets are listed by name, then the default bucket
+func bucketBefore(a string, b string) bool {
+	if a == b || a == DEFAULT_BUCKET {
+		return false
+	}
+	return b == DEFAULT_BUCKET || a < b
+}
+
+//Returns the position of a key in the listing order, the order of storage keys except
+//that keys of the default bucket, even the empty key, come after every named bucket
+func listPosition(bucket string, key string) string {
+	if bucket == DEFAULT_BUCKET {
+		return "\x01" + key
+	}
+	return storageKey(bucket, key)
+}
+
+//Returns the bucket and key at a position of the listing order
+func splitListPosition(position string) (string, string) {
+	if strings.HasPrefix(position, "\x01") {
+		return DEFAULT_BUCKET, position[1:]
+	}
+	return splitStorageKey(position)
+}
+
+//Returns the bucket and key following args.Cursor, empty strings when there is no cursor
+func listStart(args ListKeysArgs) (string, string, error) {
+	if args.Cursor == "" {
+		return "", "", nil
+	}
+	last, err := base64.RawURLEncoding.DecodeString(args.Cursor)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	// the smallest key listed after the last one returned
+	bucket, key := splitListPosition(string(last))
+	return bucket, key + "\x00", nil
+}
+
+//Lists the keys of this server, do not contact other servers.
+//Returns up to args.Limit keys in listing order
+func (s *DynamoServer) ListKeysLocal(args ListKeysArgs, result *[]BucketKey) error {
+	if s.crashed {
+		*result = nil
+		return errors.New("Crashed")
+	}
+	startBucket, startKey, err := listStart(args)
+	if err != nil {
+		return err
+	}
+	limit := scanLimit(ScanArgs{Limit: args.Limit})
+
+	keys := make([]BucketKey, 0)
+	for _, bucket := range s.index.bucketNames() {
+		if !args.AllBuckets && bucket != args.Bucket {
+			continue
+		}
+		if args.Cursor != "" && bucketBefore(bucket, startBucket) {
+			continue
+		}
+		start := ""
+		if args.Cursor != "" && bucket == startBucket {
+			start = startKey
+		}
+
+		for len(keys) < limit {
+			wanted := limit - len(keys)
+			page := s.index.scan(bucket, start, "", wanted)
+			for _, key := range page {
+				if !s.checkExpiry(storageKey(bucket, key)) {
+					keys = append(keys, BucketKey{Bucket: bucket, Key: key})
+				}
+			}
+			if len(page) < wanted {
+				break
+			}
+			start = page[len(page)-1] + "\x00"
+		}
+	}
+	*result = keys
+	return nil
+}
+
+//Lists the keys of a bucket, or of every bucket when args.AllBuckets is set, held by any node
+//of the cluster. Every node is asked for its next page of keys; keys held by several replicas
+//are listed once. When more keys may follow, result.Cursor is set: pass it back in
+//args.Cursor to get the next page
+func (s *DynamoServer) ListKeys(args ListKeysArgs, result *ListKeysResult) error {
+	if _, _, err := listStart(args); err != nil {
+		return err
+	}
+	limit := scanLimit(ScanArgs{Limit: args.Limit})
+	args.Limit = limit
+	*result = ListKeysResult{}
+
+	var local []BucketKey
+	err := s.ListKeysLocal(args, &local)
+	if err != nil {
+		return err
+	}
+	pages := [][]BucketKey{local}
+	nodes := 1
+
+	for _, node := range s.preferenceList {
+		if node == s.selfNode {
+			continue
+		}
+
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			result.Unreachable = append(result.Unreachable, node)
+			continue
+		}
+		page := clientInstance.ListKeysLocal(args)
+		clientInstance.CleanConn()
+		if page == nil {
+			result.Unreachable = append(result.Unreachable, node)
+			continue
+		}
+		nodes++
+		pages = append(pages, page)
+	}
+
+	// a node that filled its page may hold keys past its last one, stop at the smallest such key
+	cutoff := ""
+	full := false
+	for _, page := range pages {
+		if len(page) == limit && len(page) > 0 {
+			last := listPosition(page[len(page)-1].Bucket, page[len(page)-1].Key)
+			if !full || last < cutoff {
+				cutoff = last
+			}
+			full = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	listed := make([]string, 0)
+	for _, page := range pages {
+		for _, key := range page {
+			position := listPosition(key.Bucket, key.Key)
+			if full && position > cutoff {
+				break
+			}
+			if !seen[position] {
+				seen[position] = true
+				listed = append(listed, position)
+			}
+		}
+	}
+	sort.Strings(listed)
+	more := full
+	if len(listed) > limit {
+		listed = listed[:limit]
+		more = true
+	}
+
+	result.Keys = make([]BucketKey, 0, len(listed))
+	for _, position := range listed {
+		bucket, key := splitListPosition(position)
+		result.Keys = append(result.Keys, BucketKey{Bucket: bucket, Key: key})
+	}
+	if more {
+		result.Cursor = encodeCursor(listed[len(listed)-1])
+	}
+	result.Nodes = nodes
+	return nil
+}
+
+//Iterates over the keys listed by ListKeys, fetching one page at a time
+type KeyIterator struct {
+	client *RPCClient
+	args   ListKeysArgs
+	page   []BucketKey
+	pos    int
+	done   bool
+	err    error
+}
+
+//Advances to the next key, fetching the next page when needed.
+//Returns false once every key was listed or a page could not be fetched
+func (it *KeyIterator) Next() bool {
+	for it.pos+1 >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+		result, err := it.client.ListKeys(it.args)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = result.Keys
+		it.pos = -1
+		it.args.Cursor = result.Cursor
+		it.done = result.Cursor == ""
+	}
+	it.pos++
+	return true
+}
+
+//Returns the current key
+func (it *KeyIterator) Key() BucketKey {
+	return it.page[it.pos]
+}
+
+//Returns a cursor resuming the listing after the current key, to be passed as
+//ListKeysArgs.Cursor to a new iterator
+func (it *KeyIterator) Cursor() string {
+	if it.pos < 0 || it.pos >= len(it.page) {
+		return it.args.Cursor
+	}
+	return encodeCursor(listPosition(it.Key().Bucket, it.Key().Key))
+}
+
+//Returns the error that stopped the iteration, if any
+func (it *KeyIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,217 @@
+package mydynamo
+
+import (
+	"hash/crc32"
+	"time"
+)
+
+//Table of the CRC32C (Castagnoli) polynomial used for value checksums
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+//Checksum given to values whose CRC32C is 0, so that a checksum of 0 always means none
+const CHECKSUM_ZERO_CRC uint32 = 0xFFFFFFFF
+
+//Returns the CRC32C checksum of a value, never 0 (see CHECKSUM_ZERO_CRC)
+func Checksum(value []byte) uint32 {
+	sum := crc32.Checksum(value, crc32cTable)
+	if sum == 0 {
+		return CHECKSUM_ZERO_CRC
+	}
+	return sum
+}
+
+//Index terms and metadata stored alongside a version
+type versionInfo struct {
+	indexes  []IndexTerm
+	metadata ObjectMetadata
+}
+
+//Returns the index terms and client metadata of a write
+func optionsInfo(args PutOptions) versionInfo {
+	return versionInfo{
+		indexes: args.Indexes,
+		metadata: ObjectMetadata{
+			ContentType:  args.ContentType,
+			UserMetadata: args.UserMetadata,
+			Deleted:      args.Delete,
+		},
+	}
+}
+
+//Fills in the metadata the server assigns to a version that is missing from metadata:
+//the last-modified time, taken from the timestamp of the version's clock, and the checksum of its value
+func assignMetadata(metadata ObjectMetadata, value PutArgs) ObjectMetadata {
+	if metadata.LastModified.IsZero() {
+		metadata.LastModified = time.Now()
+		if value.Context.Clock.Timestamp != 0 {
+			metadata.LastModified = HLCTime(value.Context.Clock.Timestamp)
+		}
+	}
+	if metadata.Checksum == 0 {
+		metadata.Checksum = Checksum(value.Value)
+	}
+	return metadata
+}
+
+//Returns the metadata of a version that a conflict resolver made up from previous:
+//the metadata of the most recent version merged into it, with its own checksum.
+//It is a tombstone only if every version merged into it is
+func mergedMetadata(previous []ObjectEntry, metadata map[string]ObjectMetadata, merged ObjectEntry) ObjectMetadata {
+	var latest *ObjectEntry
+	deleted := len(previous) > 0
+	for idx := range previous {
+		if latest == nil || previous[idx].Context.Clock.After(latest.Context.Clock) {
+			latest = &previous[idx]
+		}
+		if !metadata[versionID(previous[idx].Context.Clock)].Deleted {
+			deleted = false
+		}
+	}
+	inherited := ObjectMetadata{}
+	if latest != nil {
+		inherited = metadata[versionID(latest.Context.Clock)]
+	}
+	inherited.Checksum = 0
+	inherited.Deleted = deleted
+	return assignMetadata(inherited, NewPutArgs("", merged.Context, merged.Value))
+}
+
+//Identifies a version of a key by its clock
+func versionID(clock VectorClock) string {
+	encoded, _ := clock.MarshalBinary()
+	return string(encoded)
+}
+
+//Returns the index terms and metadata of the version of key with the given clock.
+//Callers hold storeLock
+func (s *DynamoServer) version(key string, clock VectorClock) versionInfo {
+	return s.versions[key][versionID(clock)]
+}
+
+//Brings the index terms and metadata of key up to date with current, the plain versions
+//now in storage. previous holds the versions that were merged into them, see versionInfos
+func (s *DynamoServer) updateVersions(key string, previous []ObjectEntry, current []ObjectEntry) {
+	s.setVersions(key, versionInfos(s.versions[key], previous, current))
+}
+
+//Returns the records of current, the plain versions of a key, given records, those of the
+//versions stored so far. previous holds the versions that were merged into current: versions
+//made up by a conflict resolver inherit the terms of all of them and the metadata of the most
+//recent one, while the records of versions not in current are dropped
+func versionInfos(records map[string]versionInfo, previous []ObjectEntry, current []ObjectEntry) map[string]versionInfo {
+	inherited := versionInfo{}
+	metadata := make(map[string]ObjectMetadata)
+	for _, entry := range previous {
+		info := records[versionID(entry.Context.Clock)]
+		inherited.indexes = append(inherited.indexes, info.indexes...)
+		metadata[versionID(entry.Context.Clock)] = info.metadata
+	}
+
+	infos := make(map[string]versionInfo)
+	for _, object := range current {
+		id := versionID(object.Context.Clock)
+		info, found := records[id]
+		if !found {
+			info = versionInfo{
+				indexes:  inherited.indexes,
+				metadata: mergedMetadata(previous, metadata, object),
+			}
+		}
+		infos[id] = info
+	}
+	return infos
+}
+
+//Replaces the records of the versions of key with infos and reindexes key
+func (s *DynamoServer) setVersions(key string, infos map[string]versionInfo) {
+	if len(infos) == 0 {
+		delete(s.versions, key)
+	} else {
+		s.versions[key] = infos
+	}
+	s.reindex(key, infos)
+}
+
+//Returns the versions of key stored on this server along with their metadata
+func (s *DynamoServer) withMetadata(key string, entries []ObjectEntry) []ObjectEntryV2 {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	result := make([]ObjectEntryV2, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, ObjectEntryV2{
+			Context:  entry.Context,
+			Value:    entry.Value,
+			Metadata: s.version(key, entry.Context.Clock).metadata,
+		})
+	}
+	return result
+}
+
+//Adds the metadata of entries to metadata, by versionID
+func collectMetadata(metadata map[string]ObjectMetadata, entries []ObjectEntryV2) {
+	for _, entry := range entries {
+		metadata[versionID(entry.Context.Clock)] = entry.Metadata
+	}
+}
+
+//Strips the metadata off entries
+func plainEntries(entries []ObjectEntryV2) []ObjectEntry {
+	result := make([]ObjectEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, ObjectEntry{Context: entry.Context, Value: entry.Value})
+	}
+	return result
+}
+
+//Get a file from this server along with the metadata of each version, do not contact other servers
+func (s *DynamoServer) GetLocalV2(key string, result *[]ObjectEntryV2) error {
+	var local DynamoResult
+	err := s.GetLocal(key, &local)
+	if err != nil {
+		*result = nil
+		return err
+	}
+	*result = s.withMetadata(key, local.EntryList)
+	return nil
+}
+
+//Get a file from this server, matched with R other servers, as GetV2 does, returning
+//the content type, user metadata, last-modified time and checksum of each version
+func (s *DynamoServer) GetV3(args GetArgs, result *GetResultV3) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	metadata := make(map[string]ObjectMetadata)
+	tempRes, replicas, freshest, err := s.read(key, r, s.replicas(props), metadata)
+	if err != nil {
+		return err
+	}
+
+	quorumMet := replicas >= r
+	if !quorumMet && !args.AllowPartial {
+		*result = GetResultV3{Replicas: replicas}
+		return nil
+	}
+	entries := make([]ObjectEntryV2, 0, len(tempRes.EntryList))
+	for _, entry := range tempRes.EntryList {
+		entries = append(entries, ObjectEntryV2{
+			Context:  entry.Context,
+			Value:    entry.Value,
+			Metadata: metadata[versionID(entry.Context.Clock)],
+		})
+	}
+	*result = GetResultV3{
+		EntryList:   entries,
+		Context:     CombineContexts(tempRes.EntryList),
+		Replicas:    replicas,
+		QuorumMet:   quorumMet,
+		Coordinator: freshest,
+	}
+	return nil
+}
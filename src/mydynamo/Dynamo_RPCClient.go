@@ -74,6 +74,55 @@ func (dynamoClient *RPCClient) Put(value PutArgs) bool {
 	return result
 }
 
+//Puts a value to the server, returning the context of the version written.
+func (dynamoClient *RPCClient) PutV2(value PutArgs) *PutResult {
+	var result PutResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutV2", value, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Puts a value to the server with the consistency given in args.
+func (dynamoClient *RPCClient) PutWithOptions(args PutOptions) *PutResult {
+	var result PutResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutWithOptions", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Puts a value to the server only if the stored versions match args.Context.
+//Returns ErrConflict along with the stored versions when they do not
+func (dynamoClient *RPCClient) ConditionalPut(args PutOptions) (*ConditionalPutResult, error) {
+	var result ConditionalPutResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.ConditionalPut", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	if result.Conflict {
+		return &result, ErrConflict
+	}
+	return &result, nil
+}
+
 //Puts a value to the server, do not replicate to all other servers.
 func (dynamoClient *RPCClient) PutLocal(value PutArgs) bool {
 	var result bool
@@ -88,6 +137,20 @@ func (dynamoClient *RPCClient) PutLocal(value PutArgs) bool {
 	return true
 }
 
+//Puts a version to the server along with its index terms, do not replicate to all other servers.
+func (dynamoClient *RPCClient) PutLocalIndexed(args IndexedPutArgs) bool {
+	var result bool
+	if dynamoClient.rpcConn == nil {
+		return false
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutLocalIndexed", args, &result)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
 //Gets a value from a server.
 func (dynamoClient *RPCClient) Get(key string) *DynamoResult {
 	var result DynamoResult
@@ -103,7 +166,66 @@ func (dynamoClient *RPCClient) Get(key string) *DynamoResult {
 	return &result
 }
 
-//Gets a value from a server.
+//Gets a value from a server, reporting how many replicas answered.
+//Returns ErrQuorumNotMet, along with the number of replicas that answered, if fewer than R
+//replicas answered and args.AllowPartial is false
+func (dynamoClient *RPCClient) GetV2(args GetArgs) (*GetResult, error) {
+	var result GetResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("get conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetV2", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	if !result.QuorumMet && !args.AllowPartial {
+		return &result, ErrQuorumNotMet
+	}
+	return &result, nil
+}
+
+//Gets a value from a server along with the metadata of each version.
+//Returns ErrQuorumNotMet, along with the number of replicas that answered, if fewer than R
+//replicas answered and args.AllowPartial is false
+func (dynamoClient *RPCClient) GetV3(args GetArgs) (*GetResultV3, error) {
+	var result GetResultV3
+	if dynamoClient.rpcConn == nil {
+		log.Println("get conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetV3", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	if !result.QuorumMet && !args.AllowPartial {
+		return &result, ErrQuorumNotMet
+	}
+	return &result, nil
+}
+
+//Gets a value from a server along with the metadata of each version, do not contact other servers.
+func (dynamoClient *RPCClient) GetLocalV2(key string) []ObjectEntryV2 {
+	var result []ObjectEntryV2
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetLocalV2", key, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// gob leaves an empty list nil, which would read as a failure
+	if result == nil {
+		result = []ObjectEntryV2{}
+	}
+	return result
+}
+
+//Gets a value from a server, do not contact other servers.
+//Values of compressed buckets are returned decompressed; use GetLocalStored to get them as stored
 func (dynamoClient *RPCClient) GetLocal(key string) *DynamoResult {
 	var result DynamoResult
 	if dynamoClient.rpcConn == nil {
@@ -117,6 +239,449 @@ func (dynamoClient *RPCClient) GetLocal(key string) *DynamoResult {
 	return &result
 }
 
+//Gets the versions of a key from a server as it stores them, compressed values included
+//with their Codec set, do not contact other servers.
+func (dynamoClient *RPCClient) GetLocalStored(key string) *StoredResult {
+	var result StoredResult
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetLocalStored", key, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Puts a value to the server, using a context token returned by GetWithToken.
+func (dynamoClient *RPCClient) PutWithToken(value TokenPutArgs) bool {
+	var result bool
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return false
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutWithToken", value, &result)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return result
+}
+
+//Gets a value from a server, with contexts encoded as opaque tokens.
+func (dynamoClient *RPCClient) GetWithToken(key string) *TokenResult {
+	var result TokenResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("get conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetWithToken", key, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Creates or updates a bucket on the cluster, returning the stored settings.
+func (dynamoClient *RPCClient) SetBucket(props BucketProps) *BucketProps {
+	var result BucketProps
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.SetBucket", props, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Gets the settings of a bucket, with node defaults filled in.
+func (dynamoClient *RPCClient) GetBucket(name string) *BucketProps {
+	var result BucketProps
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetBucket", name, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Sends bucket settings to the server, do not propagate them to other servers.
+func (dynamoClient *RPCClient) PutBucketLocal(props BucketProps) bool {
+	var result bool
+	if dynamoClient.rpcConn == nil {
+		return false
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutBucketLocal", props, &result)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return result
+}
+
+//Gets several keys from the server in one round trip.
+func (dynamoClient *RPCClient) MultiGet(args MultiGetArgs) *MultiGetResult {
+	var result MultiGetResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("get conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.MultiGet", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Puts several values to the server in one round trip.
+func (dynamoClient *RPCClient) MultiPut(args MultiPutArgs) *MultiPutResult {
+	var result MultiPutResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("put conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.MultiPut", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Gets several keys from a server, do not read from other servers.
+func (dynamoClient *RPCClient) GetLocalBatch(keys []string) []LocalGetResult {
+	var result []LocalGetResult
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetLocalBatch", keys, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return result
+}
+
+//Puts several values to a server, do not replicate to other servers.
+func (dynamoClient *RPCClient) PutLocalBatch(values []IndexedPutArgs) []LocalPutResult {
+	var result []LocalPutResult
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutLocalBatch", values, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return result
+}
+
+//Adds args.Delta to a counter.
+func (dynamoClient *RPCClient) Increment(args CRDTArgs) *CRDTResult {
+	return dynamoClient.callCRDT("MyDynamo.Increment", args)
+}
+
+//Adds args.Element to a set.
+func (dynamoClient *RPCClient) AddToSet(args CRDTArgs) *CRDTResult {
+	return dynamoClient.callCRDT("MyDynamo.AddToSet", args)
+}
+
+//Removes args.Element from a set.
+func (dynamoClient *RPCClient) RemoveFromSet(args CRDTArgs) *CRDTResult {
+	return dynamoClient.callCRDT("MyDynamo.RemoveFromSet", args)
+}
+
+//Sets a register to args.Element.
+func (dynamoClient *RPCClient) SetRegister(args CRDTArgs) *CRDTResult {
+	return dynamoClient.callCRDT("MyDynamo.SetRegister", args)
+}
+
+//Gets the value of a CRDT.
+func (dynamoClient *RPCClient) Fetch(args CRDTArgs) *CRDTResult {
+	return dynamoClient.callCRDT("MyDynamo.Fetch", args)
+}
+
+//Calls one of the CRDT operations of the server
+func (dynamoClient *RPCClient) callCRDT(method string, args CRDTArgs) *CRDTResult {
+	var result CRDTResult
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call(method, args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Scans a range of keys of a bucket, merging the replies of R replicas.
+func (dynamoClient *RPCClient) Scan(args ScanArgs) (*ScanResult, error) {
+	return dynamoClient.callScan("MyDynamo.Scan", args)
+}
+
+//Scans the keys of a bucket starting with args.Prefix, merging the replies of R replicas.
+func (dynamoClient *RPCClient) ScanPrefix(args ScanArgs) (*ScanResult, error) {
+	return dynamoClient.callScan("MyDynamo.ScanPrefix", args)
+}
+
+//Calls one of the scan RPCs
+func (dynamoClient *RPCClient) callScan(method string, args ScanArgs) (*ScanResult, error) {
+	var result ScanResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("scan conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call(method, args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	if !result.QuorumMet && !args.AllowPartial {
+		return &result, ErrQuorumNotMet
+	}
+	return &result, nil
+}
+
+//Scans a range of keys of a server, do not contact other servers.
+func (dynamoClient *RPCClient) ScanLocal(args ScanArgs) []ScanEntry {
+	var result []ScanEntry
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.ScanLocal", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// gob leaves an empty page nil, which would read as a failure
+	if result == nil {
+		result = []ScanEntry{}
+	}
+	return result
+}
+
+//Lists a page of the keys held by any node of the cluster.
+func (dynamoClient *RPCClient) ListKeys(args ListKeysArgs) (*ListKeysResult, error) {
+	var result ListKeysResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("list conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.ListKeys", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	return &result, nil
+}
+
+//Lists a page of the keys of a server, do not contact other servers.
+func (dynamoClient *RPCClient) ListKeysLocal(args ListKeysArgs) []BucketKey {
+	var result []BucketKey
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.ListKeysLocal", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// gob leaves an empty page nil, which would read as a failure
+	if result == nil {
+		result = []BucketKey{}
+	}
+	return result
+}
+
+//Returns an iterator over every key listed by ListKeys, starting at args.Cursor.
+func (dynamoClient *RPCClient) IterateKeys(args ListKeysArgs) *KeyIterator {
+	return &KeyIterator{
+		client: dynamoClient,
+		args:   args,
+		pos:    -1,
+	}
+}
+
+//Gets the keys of a bucket indexed under a term on any node of the cluster.
+func (dynamoClient *RPCClient) IndexQuery(args IndexQueryArgs) (*IndexQueryResult, error) {
+	var result IndexQueryResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("query conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.IndexQuery", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	return &result, nil
+}
+
+//Gets the keys of a server indexed under a term, do not contact other servers.
+func (dynamoClient *RPCClient) IndexQueryLocal(args IndexQueryArgs) []string {
+	var result []string
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.IndexQueryLocal", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// gob leaves an empty result nil, which would read as a failure
+	if result == nil {
+		result = []string{}
+	}
+	return result
+}
+
+//Gets the counters of the server.
+func (dynamoClient *RPCClient) Stats() *ServerStats {
+	var result ServerStats
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.Stats", Empty{}, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Stores a chunk on the server and W - 1 other replicas of args.Bucket
+func (dynamoClient *RPCClient) PutChunk(args ChunkArgs) *ChunkResult {
+	var result ChunkResult
+	if dynamoClient.rpcConn == nil {
+		log.Println("put chunk conn nil")
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutChunk", args, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Stores a chunk on the server, do not replicate to other servers. Returns its ID, empty on failure
+func (dynamoClient *RPCClient) PutChunkLocal(data []byte) string {
+	var result string
+	if dynamoClient.rpcConn == nil {
+		return ""
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PutChunkLocal", data, &result)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return result
+}
+
+//Gets a chunk from the server, which asks the other nodes when it does not have it.
+//Returns ErrChunkNotFound if no node has it and ErrChunkCorrupted if it does not match id
+func (dynamoClient *RPCClient) GetChunk(id string) ([]byte, error) {
+	var result []byte
+	if dynamoClient.rpcConn == nil {
+		log.Println("get chunk conn nil")
+		return nil, rpc.ErrShutdown
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetChunk", id, &result)
+	if err != nil {
+		log.Println(err)
+		return nil, serverError(err)
+	}
+	if ChunkID(result) != id {
+		return nil, ErrChunkCorrupted
+	}
+	return result, nil
+}
+
+//Gets a chunk from the server, do not contact other servers. Returns nil on failure
+func (dynamoClient *RPCClient) GetChunkLocal(id string) []byte {
+	var result []byte
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.GetChunkLocal", id, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// gob leaves an empty chunk nil, which would read as a failure
+	if result == nil {
+		result = []byte{}
+	}
+	return result
+}
+
+//Deletes the chunks of the server that are unreferenced and older than olderThan seconds.
+//Returns the number of chunks deleted, -1 on failure
+func (dynamoClient *RPCClient) CollectChunks(olderThan int) int {
+	var result int
+	if dynamoClient.rpcConn == nil {
+		return -1
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.CollectChunks", olderThan, &result)
+	if err != nil {
+		log.Println(err)
+		return -1
+	}
+	return result
+}
+
+//Asks the server to send its versions of a storage key to args.Node.
+//Returns false if it holds no healthy version or could not reach args.Node
+func (dynamoClient *RPCClient) PushKey(args PushArgs) bool {
+	var result bool
+	if dynamoClient.rpcConn == nil {
+		return false
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.PushKey", args, &result)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return result
+}
+
+//Checks the values stored on the server against their checksums and repairs corrupt ones
+func (dynamoClient *RPCClient) Scrub() *ScrubResult {
+	var result ScrubResult
+	if dynamoClient.rpcConn == nil {
+		return nil
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.Scrub", Empty{}, &result)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &result
+}
+
+//Emulates bit rot in the first version of a storage key on the server this client is connected to
+func (dynamoClient *RPCClient) Corrupt(key string) bool {
+	var result bool
+	if dynamoClient.rpcConn == nil {
+		return false
+	}
+	err := dynamoClient.rpcConn.Call("MyDynamo.Corrupt", key, &result)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return result
+}
+
 //Emulates a crash on the server this client is connected to
 func (dynamoClient *RPCClient) Crash(seconds int) bool {
 	if dynamoClient.rpcConn == nil {
@@ -144,6 +709,37 @@ func (dynamoClient *RPCClient) Gossip() {
 	}
 }
 
+//Maps an error returned through RPC back to the matching error value of this package
+func serverError(err error) error {
+	switch err.Error() {
+	case ErrQuorumNotMet.Error():
+		return ErrQuorumNotMet
+	case ErrInvalidConsistency.Error():
+		return ErrInvalidConsistency
+	case ErrInvalidBucket.Error():
+		return ErrInvalidBucket
+	case ErrInvalidKey.Error():
+		return ErrInvalidKey
+	case ErrCRDTType.Error():
+		return ErrCRDTType
+	case ErrInvalidCursor.Error():
+		return ErrInvalidCursor
+	case ErrInvalidIndexTerm.Error():
+		return ErrInvalidIndexTerm
+	case ErrChunkNotFound.Error():
+		return ErrChunkNotFound
+	case ErrChecksumMismatch.Error():
+		return ErrChecksumMismatch
+	case ErrDecompressedTooLarge.Error():
+		return ErrDecompressedTooLarge
+	case ErrStorageFull.Error():
+		return ErrStorageFull
+	case ErrClockDrift.Error():
+		return ErrClockDrift
+	}
+	return err
+}
+
 //Creates a new DynamoRPCClient
 func NewDynamoRPCClient(serverAddr string) *RPCClient {
 	return &RPCClient{
@@ -0,0 +1,116 @@
+package mydynamo
+
+import (
+	"log"
+	"sync"
+)
+
+//Built-in conflict modes, usable as BucketProps.ConflictMode
+const CONFLICT_SIBLINGS string = "siblings" //Keep every concurrent version and let clients merge them
+const CONFLICT_LWW string = "lww"           //Keep the version with the latest hybrid timestamp, then highest writer ID
+const CONFLICT_UNION string = "union"       //Merge values as bitsets, OR-ing them byte by byte
+
+//Reconciles concurrent versions of a key. Resolve is called with two or more siblings
+//and returns the versions to keep, usually a single merged entry built with MergeSiblings
+type ConflictResolver interface {
+	Resolve(siblings []ObjectEntry) []ObjectEntry
+}
+
+//Adapts a Go function that merges sibling values into a ConflictResolver
+type ResolverFunc func(siblings []ObjectEntry) []byte
+
+//Keeps every sibling, leaving the merge to the client
+type KeepSiblingsResolver struct{}
+
+//Keeps the sibling written last, according to VectorClock.After
+type LastWriterWinsResolver struct{}
+
+//Merges set-like values by OR-ing them byte by byte
+type UnionResolver struct{}
+
+var resolversLock sync.RWMutex
+var resolvers = map[string]ConflictResolver{
+	CONFLICT_SIBLINGS: KeepSiblingsResolver{},
+	CONFLICT_LWW:      LastWriterWinsResolver{},
+	CONFLICT_UNION:    UnionResolver{},
+}
+
+//Makes a resolver available to buckets under the given conflict mode name.
+//Custom resolvers must be registered on every node before it starts serving
+func RegisterConflictResolver(name string, resolver ConflictResolver) {
+	resolversLock.Lock()
+	defer resolversLock.Unlock()
+	resolvers[name] = resolver
+}
+
+//Returns the resolver registered under name
+func lookupResolver(name string) (ConflictResolver, bool) {
+	resolversLock.RLock()
+	defer resolversLock.RUnlock()
+	resolver, found := resolvers[name]
+	return resolver, found
+}
+
+//Builds the single entry replacing siblings: it holds value and a clock combining
+//every sibling's clock, so it supersedes all of them on every replica
+func MergeSiblings(siblings []ObjectEntry, value []byte) []ObjectEntry {
+	return []ObjectEntry{{
+		Context: CombineContexts(siblings),
+		Value:   value,
+	}}
+}
+
+func (f ResolverFunc) Resolve(siblings []ObjectEntry) []ObjectEntry {
+	return MergeSiblings(siblings, f(siblings))
+}
+
+func (KeepSiblingsResolver) Resolve(siblings []ObjectEntry) []ObjectEntry {
+	return siblings
+}
+
+func (LastWriterWinsResolver) Resolve(siblings []ObjectEntry) []ObjectEntry {
+	winner := siblings[0]
+	for _, sibling := range siblings[1:] {
+		if sibling.Context.Clock.After(winner.Context.Clock) {
+			winner = sibling
+		}
+	}
+	return MergeSiblings(siblings, winner.Value)
+}
+
+func (UnionResolver) Resolve(siblings []ObjectEntry) []ObjectEntry {
+	union := make([]byte, 0)
+	for _, sibling := range siblings {
+		for idx, b := range sibling.Value {
+			if idx < len(union) {
+				union[idx] |= b
+			} else {
+				union = append(union, b)
+			}
+		}
+	}
+	return MergeSiblings(siblings, union)
+}
+
+//Reconciles the versions of a key: CRDTs are always merged, other values go
+//through the resolver of the key's bucket
+func (s *DynamoServer) resolve(key string, entries []ObjectEntry) []ObjectEntry {
+	if len(entries) < 2 {
+		return entries
+	}
+	if allCRDTs(entries) {
+		return crdtResolver{}.Resolve(entries)
+	}
+	bucket, _ := splitStorageKey(key)
+	mode := s.bucket(bucket).ConflictMode
+	// OR-ing manifests would reference chunks that do not exist
+	if mode == CONFLICT_UNION && anyManifest(entries) {
+		mode = CONFLICT_SIBLINGS
+	}
+	resolver, found := lookupResolver(mode)
+	if !found {
+		log.Println(DYNAMO_SERVER, "no conflict resolver registered for", mode)
+		return entries
+	}
+	return resolver.Resolve(entries)
+}
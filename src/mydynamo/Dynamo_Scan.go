@@ -0,0 +1,273 @@
+package mydynamo
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"sync"
+)
+
+//Number of keys returned by a scan that does not set a limit
+const SCAN_DEFAULT_LIMIT int = 100
+
+var ErrInvalidCursor = errors.New("invalid scan cursor")
+
+//Keys held by a node, sorted within each bucket
+type keyIndex struct {
+	lock    *sync.RWMutex
+	buckets map[string][]string
+}
+
+//Creates an empty index
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		lock:    &sync.RWMutex{},
+		buckets: make(map[string][]string),
+	}
+}
+
+//Adds a storage key to the index, if it is not there yet
+func (index *keyIndex) insert(key string) {
+	bucket, key := splitStorageKey(key)
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	keys := index.buckets[bucket]
+	idx := sort.SearchStrings(keys, key)
+	if idx < len(keys) && keys[idx] == key {
+		return
+	}
+	keys = append(keys, "")
+	copy(keys[idx+1:], keys[idx:])
+	keys[idx] = key
+	index.buckets[bucket] = keys
+}
+
+//Removes a storage key from the index
+func (index *keyIndex) remove(key string) {
+	bucket, key := splitStorageKey(key)
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	keys := index.buckets[bucket]
+	idx := sort.SearchStrings(keys, key)
+	if idx < len(keys) && keys[idx] == key {
+		index.buckets[bucket] = append(keys[:idx], keys[idx+1:]...)
+	}
+}
+
+//Returns up to limit keys of bucket from start (inclusive) to end (exclusive, unbounded when empty)
+func (index *keyIndex) scan(bucket string, start string, end string, limit int) []string {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	keys := index.buckets[bucket]
+	result := make([]string, 0)
+	for idx := sort.SearchStrings(keys, start); idx < len(keys) && len(result) < limit; idx++ {
+		if end != "" && keys[idx] >= end {
+			break
+		}
+		result = append(result, keys[idx])
+	}
+	return result
+}
+
+//Encodes the last key returned by a scan as an opaque continuation token
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+//Returns the first key of the range scanned by args, after its cursor if any
+func scanStart(args ScanArgs) (string, error) {
+	if args.Cursor == "" {
+		return args.StartKey, nil
+	}
+	last, err := base64.RawURLEncoding.DecodeString(args.Cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	// the smallest key sorting after the last one returned
+	next := string(last) + "\x00"
+	if next < args.StartKey {
+		return args.StartKey, nil
+	}
+	return next, nil
+}
+
+//Returns the key after every key starting with prefix, or "" if there is none
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+//Returns the number of keys a scan asks for
+func scanLimit(args ScanArgs) int {
+	if args.Limit <= 0 {
+		return SCAN_DEFAULT_LIMIT
+	}
+	return args.Limit
+}
+
+//Scans the keys of this server in the range of args, do not contact other servers.
+//Returns up to args.Limit keys in order, with their versions
+func (s *DynamoServer) ScanLocal(args ScanArgs, result *[]ScanEntry) error {
+	if s.crashed {
+		*result = nil
+		return errors.New("Crashed")
+	}
+	start, err := scanStart(args)
+	if err != nil {
+		return err
+	}
+	limit := scanLimit(args)
+
+	entries := make([]ScanEntry, 0)
+	for len(entries) < limit {
+		wanted := limit - len(entries)
+		keys := s.index.scan(args.Bucket, start, args.EndKey, wanted)
+		for _, key := range keys {
+			var local DynamoResult
+			if err := s.GetLocal(storageKey(args.Bucket, key), &local); err != nil {
+				*result = nil
+				return err
+			}
+			// expired while scanning
+			if len(local.EntryList) == 0 {
+				continue
+			}
+			entries = append(entries, ScanEntry{
+				Key:       key,
+				EntryList: local.EntryList,
+			})
+		}
+		if len(keys) < wanted {
+			break
+		}
+		start = keys[len(keys)-1] + "\x00"
+	}
+	*result = entries
+	return nil
+}
+
+//Scans the keys of a bucket from args.StartKey to args.EndKey (exclusive, unbounded when empty)
+//in order, merging the keys and versions read from R replicas. When more keys may follow,
+//result.Cursor is set: pass it back in args.Cursor to get the next page
+func (s *DynamoServer) Scan(args ScanArgs, result *ScanResult) error {
+	if _, err := scanStart(args); err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	limit := scanLimit(args)
+	args.Limit = limit
+
+	var local []ScanEntry
+	err = s.ScanLocal(args, &local)
+	if err != nil {
+		return err
+	}
+	pages := [][]ScanEntry{local}
+
+	// make call to r - 1 servers
+	cnt := 0
+	for _, node := range s.replicas(props) {
+		if cnt == r-1 {
+			break
+		}
+		if node == s.selfNode {
+			continue
+		}
+
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		page := clientInstance.ScanLocal(args)
+		clientInstance.CleanConn()
+		if page == nil {
+			continue
+		}
+		cnt++
+		pages = append(pages, page)
+	}
+
+	if cnt+1 < r && !args.AllowPartial {
+		*result = ScanResult{Replicas: cnt + 1}
+		return nil
+	}
+	*result = s.mergeScans(args.Bucket, pages, limit)
+	result.Replicas = cnt + 1
+	result.QuorumMet = cnt+1 >= r
+	return nil
+}
+
+//Scans the keys of a bucket starting with args.Prefix, as Scan does for a range
+func (s *DynamoServer) ScanPrefix(args ScanArgs, result *ScanResult) error {
+	args.StartKey = args.Prefix
+	args.EndKey = prefixEnd(args.Prefix)
+	return s.Scan(args, result)
+}
+
+//Merges pages of up to limit keys read from several replicas, reconciling the versions
+//of keys read from more than one. A replica that filled its page may hold keys past its
+//last one, so the merged page stops at the smallest such key
+func (s *DynamoServer) mergeScans(bucket string, pages [][]ScanEntry, limit int) ScanResult {
+	cutoff := ""
+	full := false
+	for _, page := range pages {
+		if len(page) == limit && len(page) > 0 {
+			last := page[len(page)-1].Key
+			if !full || last < cutoff {
+				cutoff = last
+			}
+			full = true
+		}
+	}
+
+	merged := make(map[string][]ObjectEntry)
+	keys := make([]string, 0)
+	for _, page := range pages {
+		for _, entry := range page {
+			if full && entry.Key > cutoff {
+				break
+			}
+			list, found := merged[entry.Key]
+			if !found {
+				keys = append(keys, entry.Key)
+				list = append([]ObjectEntry{}, entry.EntryList...)
+			} else {
+				list = reconcile(list, entry.EntryList)
+			}
+			merged[entry.Key] = list
+		}
+	}
+	sort.Strings(keys)
+	more := full
+	if len(keys) > limit {
+		keys = keys[:limit]
+		more = true
+	}
+
+	result := ScanResult{Entries: make([]ScanEntry, 0, len(keys))}
+	for _, key := range keys {
+		list := s.resolve(storageKey(bucket, key), merged[key])
+		result.Entries = append(result.Entries, ScanEntry{
+			Key:       key,
+			EntryList: list,
+			Context:   CombineContexts(list),
+		})
+	}
+	if more {
+		result.Cursor = encodeCursor(keys[len(keys)-1])
+	}
+	return result
+}
@@ -0,0 +1,242 @@
+package mydynamo
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+var ErrChecksumMismatch = errors.New("value does not match its checksum")
+
+//Returns false if entry carries a checksum that its value does not match.
+//Entries without a checksum, from older nodes, are taken as they are
+func validEntry(entry StoredEntry) bool {
+	return entry.Checksum == 0 || Checksum(entry.Value) == entry.Checksum
+}
+
+//Returns false if a stored entry does not match its checksum. Every stored entry
+//is given one, so one without a checksum has had it corrupted
+func intactEntry(entry StoredEntry) bool {
+	return entry.Checksum != 0 && validEntry(entry)
+}
+
+//Returns entry with the checksum of its value
+func withChecksum(entry StoredEntry) StoredEntry {
+	entry.Checksum = Checksum(entry.Value)
+	return entry
+}
+
+//Checks the stored versions of key against their checksums. Corrupt versions are
+//dropped and the key is left for the scrubber to repair from another replica.
+//Callers hold storeLock
+func (s *DynamoServer) verify(key string) {
+	objects, found := s.storage[key]
+	if !found {
+		return
+	}
+	healthy := make([]StoredEntry, 0, len(objects))
+	for _, obj := range objects {
+		if intactEntry(obj) {
+			healthy = append(healthy, obj)
+		}
+	}
+	corrupt := len(objects) - len(healthy)
+	if corrupt == 0 {
+		return
+	}
+
+	log.Println(DYNAMO_SERVER, "dropping", corrupt, "corrupt versions of", key)
+	if len(healthy) == 0 {
+		delete(s.storage, key)
+		s.index.remove(key)
+	} else {
+		s.storage[key] = healthy
+	}
+	s.updateVersions(key, nil, clocksOf(healthy))
+	s.account(key)
+	s.markDamaged(key, corrupt)
+}
+
+//Checks the versions of key against their checksums wherever they are stored. A spilled key
+//is read in place, and only brought back into memory when it has corrupt versions to drop.
+//Callers hold storeLock
+func (s *DynamoServer) scrubKey(key string) {
+	if s.isSpilled(key) {
+		entries, err := s.readSpill(key)
+		healthy := err == nil
+		for _, entry := range entries {
+			healthy = healthy && intactEntry(entry)
+		}
+		if healthy {
+			return
+		}
+		s.load(key)
+	}
+	s.verify(key)
+}
+
+//Records that corrupt versions of key were dropped, for the scrubber to repair.
+//Callers hold storeLock
+func (s *DynamoServer) markDamaged(key string, corrupt int) {
+	atomic.AddInt64(&s.counters.corruptionDetected, int64(corrupt))
+	s.damaged[key] += corrupt
+}
+
+//Returns the writes that send every stored version of key, with its index terms and
+//metadata, to another node. Callers hold storeLock
+func (s *DynamoServer) pushArgs(key string) []IndexedPutArgs {
+	writes := make([]IndexedPutArgs, 0)
+	for _, object := range s.peek(key) {
+		info := s.version(key, object.Context.Clock)
+		writes = append(writes, IndexedPutArgs{
+			Value:    NewPutArgs(key, object.Context, object.Value),
+			Indexes:  info.indexes,
+			Metadata: info.metadata,
+			Codec:    object.Codec,
+			Checksum: object.Checksum,
+		})
+	}
+	return writes
+}
+
+//Sends the writes returned by pushArgs to the node clientInstance is connected to
+func pushKey(clientInstance *RPCClient, writes []IndexedPutArgs) {
+	for _, write := range writes {
+		clientInstance.PutLocalIndexed(write)
+	}
+}
+
+//Sends the versions of args.Key held by this server to args.Node, so that it can replace
+//versions it found corrupt. Returns false if this server holds no healthy version
+func (s *DynamoServer) PushKey(args PushArgs, result *bool) error {
+	if s.crashed {
+		*result = false
+		return errors.New("Crashed")
+	}
+	s.storeLock.Lock()
+	s.load(args.Key)
+	s.verify(args.Key)
+	var writes []IndexedPutArgs
+	if !s.expire(args.Key) {
+		writes = s.pushArgs(args.Key)
+	}
+	s.storeLock.Unlock()
+	if len(writes) == 0 {
+		*result = false
+		return nil
+	}
+
+	clientInstance := NewDynamoRPCClient(args.Node.Address + ":" + args.Node.Port)
+	if err := clientInstance.RpcConnect(); err != nil {
+		*result = false
+		return err
+	}
+	defer clientInstance.CleanConn()
+	pushKey(clientInstance, writes)
+	*result = true
+	return nil
+}
+
+//Asks the other nodes, in turn, for the versions of a key that had corrupt versions
+//dropped. Returns true once a node sent its versions
+func (s *DynamoServer) repair(key string) bool {
+	for _, node := range s.preferenceList {
+		if node == s.selfNode {
+			continue
+		}
+		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
+		if clientInstance.RpcConnect() != nil {
+			continue
+		}
+		pushed := clientInstance.PushKey(PushArgs{Key: key, Node: s.selfNode})
+		clientInstance.CleanConn()
+		if pushed {
+			return true
+		}
+	}
+	return false
+}
+
+//Checks every version stored on this server, in memory or spilled, against its checksum,
+//then repairs the keys with corrupt versions from healthy replicas. Keys no replica could
+//repair are retried on the next pass
+func (s *DynamoServer) Scrub(_ Empty, result *ScrubResult) error {
+	if s.crashed {
+		return errors.New("Crashed")
+	}
+	*result = ScrubResult{}
+	s.storeLock.Lock()
+	keys := s.storedKeys()
+	s.storeLock.Unlock()
+	detected := atomic.LoadInt64(&s.counters.corruptionDetected)
+	for _, key := range keys {
+		// one key at a time, so that requests are served while scrubbing
+		s.storeLock.Lock()
+		s.scrubKey(key)
+		s.storeLock.Unlock()
+	}
+	result.Keys = len(keys)
+	result.Corrupt = int(atomic.LoadInt64(&s.counters.corruptionDetected) - detected)
+
+	s.storeLock.Lock()
+	damaged := make(map[string]int, len(s.damaged))
+	for key, corrupt := range s.damaged {
+		damaged[key] = corrupt
+	}
+	s.storeLock.Unlock()
+	// the lock is not held while repairing: the replicas send the versions back through PutLocalIndexed
+	for key, corrupt := range damaged {
+		if !s.repair(key) {
+			continue
+		}
+		log.Println(DYNAMO_SERVER, "repaired", key, "from another replica")
+		s.storeLock.Lock()
+		s.damaged[key] -= corrupt
+		if s.damaged[key] <= 0 {
+			delete(s.damaged, key)
+		}
+		s.storeLock.Unlock()
+		atomic.AddInt64(&s.counters.corruptionRepaired, int64(corrupt))
+		result.Repaired += corrupt
+	}
+	return nil
+}
+
+//Scrubs the server every interval seconds; 0, the default, disables background scrubbing
+func (s *DynamoServer) SetScrubInterval(interval int) {
+	s.scrubInterval = interval
+}
+
+//Runs Scrub every scrubInterval seconds while the server is up
+func (s *DynamoServer) scrubLoop() {
+	for {
+		time.Sleep(time.Duration(s.scrubInterval) * time.Second)
+		if s.crashed {
+			continue
+		}
+		var result ScrubResult
+		s.Scrub(Empty{}, &result)
+		if result.Corrupt > 0 || result.Repaired > 0 {
+			log.Println(DYNAMO_SERVER, "scrubbed", result.Keys, "keys:", result.Corrupt, "corrupt versions,", result.Repaired, "repaired")
+		}
+	}
+}
+
+//Emulates bit rot: flips a bit of the first stored version of key, leaving its checksum as is.
+//Returns false if the key is not stored on this server
+func (s *DynamoServer) Corrupt(key string, success *bool) error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	s.load(key)
+	objects := s.storage[key]
+	if len(objects) == 0 || len(objects[0].Value) == 0 {
+		*success = false
+		return nil
+	}
+	value := append([]byte{}, objects[0].Value...)
+	value[0] ^= 1
+	objects[0].Value = value
+	*success = true
+	return nil
+}
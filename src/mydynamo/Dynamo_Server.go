@@ -6,9 +6,13 @@ import (
 	"net"
 	"net/http"
 	"net/rpc"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var ErrQuorumNotMet = errors.New("read quorum not met")
+
 type DynamoServer struct {
 	/*------------Dynamo-specific-------------*/
 	wValue         int                      //Number of nodes to write to on each Put
@@ -16,8 +20,48 @@ type DynamoServer struct {
 	preferenceList []DynamoNode             //Ordered list of other Dynamo nodes to perform operations o
 	selfNode       DynamoNode               //This node's address and port info
 	nodeID         string                   //ID of this node
-	storage        map[string][]ObjectEntry // concurrent
+	storage        map[string][]StoredEntry // concurrent
+	storeLock      *sync.Mutex              //Guards storage, chunks, the spill tier and the records kept for each stored key
 	crashed        bool
+	jsonRPCPort    string                 //Port serving the JSON-RPC endpoint, empty when disabled
+	httpPort       string                 //Port serving the REST gateway, empty when disabled
+	contextKey     []byte                 //HMAC key for context tokens, tokens are unsigned when empty
+	buckets        map[string]BucketProps //Cluster metadata: settings of each named bucket
+	bucketLock     *sync.RWMutex          //Guards buckets
+	expiry         map[string]time.Time   //Expiry time of keys in buckets with a TTL
+	casLock        *sync.Mutex            //Serializes conditional writes and CRDT updates coordinated by this node
+	hlc            *HybridClock           //Assigns timestamps to the writes coordinated by this node
+	clockThreshold int                    //Maximum number of vector clock entries, 0 to never prune
+	counters       *serverCounters
+	index          *keyIndex                         //Ordered keys of storage, for scans
+	postings       *keyIndex                         //Local secondary index: an entry per index term and key
+	versions       map[string]map[string]versionInfo //Index terms and metadata of the stored versions, by storage key and versionID
+	keyTerms       map[string][]string               //Entries of postings for each storage key
+	dots           map[string]int                    //Last dot counter this node handed out on each storage key
+	chunks         *chunkStore                       //Chunks of the large values stored as manifests
+	damaged        map[string]int                    //Number of corrupt versions dropped from each storage key, until repaired
+	spill          *spillTier                        //Memory budget and disk tier of storage
+	scrubInterval  int                               //Seconds between background scrubs, 0 to disable them
+}
+
+//Enables the JSON-RPC endpoint for non-Go clients on the given port
+func (s *DynamoServer) SetJSONRPCPort(port string) {
+	s.jsonRPCPort = port
+}
+
+//Enables the REST gateway on the given port
+func (s *DynamoServer) SetHTTPPort(port string) {
+	s.httpPort = port
+}
+
+//Prunes the oldest entries of the clocks of new versions once they exceed threshold entries
+func (s *DynamoServer) SetClockPruneThreshold(threshold int) {
+	s.clockThreshold = threshold
+}
+
+//Signs context tokens handed out by this server with key, and rejects unsigned or tampered ones
+func (s *DynamoServer) SetContextKey(key []byte) {
+	s.contextKey = key
 }
 
 func (s *DynamoServer) SendPreferenceList(incomingList []DynamoNode, _ *Empty) error {
@@ -32,11 +76,31 @@ func (s *DynamoServer) Gossip(_ Empty, _ *Empty) error {
 		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
 		clientInstance.RpcConnect()
 
-		for key, entry := range s.storage {
-			for _, object := range entry {
-				clientInstance.PutLocal(NewPutArgs(key, object.Context, object.Value))
+		for _, props := range s.bucketSnapshot() {
+			clientInstance.PutBucketLocal(props)
+		}
+
+		// chunks go first so that no node holds a manifest without its chunks
+		for id := range s.referencedChunks() {
+			var data []byte
+			if s.GetChunkLocal(id, &data) == nil {
+				clientInstance.PutChunkLocal(data)
 			}
 		}
+
+		s.storeLock.Lock()
+		keys := s.storedKeys()
+		s.storeLock.Unlock()
+		for _, key := range keys {
+			s.storeLock.Lock()
+			var writes []IndexedPutArgs
+			if !s.expire(key) {
+				s.verify(key)
+				writes = s.pushArgs(key)
+			}
+			s.storeLock.Unlock()
+			pushKey(clientInstance, writes)
+		}
 	}
 	return nil
 }
@@ -52,28 +116,69 @@ func (s *DynamoServer) Crash(seconds int, success *bool) error {
 
 // Put a file to this server
 func (s *DynamoServer) PutLocal(value PutArgs, result *bool) error {
+	return s.PutLocalIndexed(IndexedPutArgs{Value: value}, result)
+}
+
+//Puts a version to this server along with its index terms and metadata, do not replicate to other servers
+func (s *DynamoServer) PutLocalIndexed(args IndexedPutArgs, result *bool) error {
+	value := args.Value
 	if s.crashed {
 		*result = false
 		return errors.New("Crashed")
 	}
 
-	// increase clock value, value -> clock
-	vectorClock := value.Context.Clock
+	// values travel compressed, keep them that way in storage
+	received := StoredEntry{
+		Context:  value.Context,
+		Value:    value.Value,
+		Codec:    args.Codec,
+		Checksum: args.Checksum,
+	}
+	if !validEntry(received) {
+		log.Println(DYNAMO_SERVER, "rejecting corrupt version of", value.Key)
+		atomic.AddInt64(&s.counters.corruptionDetected, 1)
+		*result = false
+		return ErrChecksumMismatch
+	}
+	newObject, err := decompressEntry(received)
+	if err != nil {
+		*result = false
+		return err
+	}
+	value.Value = newObject.Value
 	key := value.Key
-	storeValue := value.Value
-	newObject := ObjectEntry{
-		Context: value.Context,
-		Value:   storeValue,
+
+	// a timestamp far in the future would drag this node's clock along with it
+	vectorClock := value.Context.Clock
+	if err := checkDrift(vectorClock.Timestamp); err != nil {
+		log.Println(DYNAMO_SERVER, "rejecting version of", key, "from the future")
+		*result = false
+		return err
 	}
 
+	// gossip and repairs may bring back versions whose TTL passed since they were written
+	metadata := assignMetadata(args.Metadata, value)
+	if deadline, ok := s.deadline(key, metadata.LastModified); ok && !time.Now().Before(deadline) {
+		log.Println(DYNAMO_SERVER, "skipping expired version of", key)
+		*result = false
+		return nil
+	}
+
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	s.load(key)
+	s.verify(key)
+
 	bigger := false
 	concur := true
-	for idx := 0; idx < len(s.storage[key]); idx++ {
-		obj := s.storage[key][idx]
+	// work on a copy, storage is left as is if the write is rejected
+	objects := append([]StoredEntry{}, s.storage[key]...)
+	for idx := 0; idx < len(objects); idx++ {
+		obj := objects[idx]
 
 		if obj.Context.Clock.LessThan(vectorClock) {
 			bigger = true
-			s.storage[key] = remove(s.storage[key], idx)
+			objects = remove(objects, idx)
 			idx--
 		}
 
@@ -89,7 +194,44 @@ func (s *DynamoServer) PutLocal(value PutArgs, result *bool) error {
 	}
 
 	if bigger || concur {
-		s.storage[key] = append(s.storage[key], newObject)
+		stored, err := decompressEntries(objects)
+		if err != nil {
+			*result = false
+			return err
+		}
+		encoded := make(map[string]StoredEntry)
+		for _, obj := range objects {
+			encoded[versionID(obj.Context.Clock)] = obj
+		}
+		if received.Codec != CODEC_NONE {
+			encoded[versionID(vectorClock)] = withChecksum(received)
+		}
+
+		previous := append(stored, newObject)
+		records := make(map[string]versionInfo, len(s.versions[key])+1)
+		for id, info := range s.versions[key] {
+			records[id] = info
+		}
+		records[versionID(vectorClock)] = versionInfo{
+			indexes:  args.Indexes,
+			metadata: metadata,
+		}
+		current := s.resolve(key, previous)
+		entries := s.encodeEntries(key, current, encoded)
+		infos := versionInfos(records, previous, current)
+
+		// only what the write adds to the key counts against the budget
+		if err := s.makeRoom(key, memorySize(key, entries, infos)-s.accounted(key)); err != nil {
+			log.Println(DYNAMO_SERVER, "rejecting write of", key, err)
+			*result = false
+			return err
+		}
+		s.storage[key] = entries
+		s.setVersions(key, infos)
+		s.account(key)
+		s.index.insert(key)
+		s.touch(key)
+		s.hlc.Update(vectorClock.Timestamp)
 		*result = true
 		return nil
 	}
@@ -101,10 +243,76 @@ func (s *DynamoServer) PutLocal(value PutArgs, result *bool) error {
 
 // Put a file to this server and W other servers
 func (s *DynamoServer) Put(value PutArgs, result *bool) error {
-	// first put to local storage
-	value.Context.Clock.Increment(s.nodeID)
+	var res PutResult
+	err := s.PutV2(value, &res)
+	*result = res.Success
+	return err
+}
+
+// Put a file to this server and W other servers, reporting the version that was written
+func (s *DynamoServer) PutV2(value PutArgs, result *PutResult) error {
+	return s.PutWithOptions(PutOptions{
+		Key:     value.Key,
+		Context: value.Context,
+		Value:   value.Value,
+	}, result)
+}
+
+// Put a file to this server and as many other servers as the bucket and the requested consistency need
+func (s *DynamoServer) PutWithOptions(args PutOptions, result *PutResult) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(args.Bucket)
+	w, err := writeQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	if err := validateTerms(args.Indexes); err != nil {
+		return err
+	}
+	if args.Coordinator != (DynamoNode{}) && args.Coordinator != s.selfNode {
+		if forwarded, err := s.forward(args, result); forwarded {
+			return err
+		}
+	}
+	return s.put(NewPutArgs(key, args.Context, args.Value), optionsInfo(args), w, s.replicas(props), result)
+}
+
+//Hands a write over to args.Coordinator, returning the coordinator's error if it failed there.
+//Returns false if the coordinator could not be reached, so that this node coordinates the write instead
+func (s *DynamoServer) forward(args PutOptions, result *PutResult) (bool, error) {
+	coordinator := args.Coordinator
+	args.Coordinator = DynamoNode{}
+
+	clientInstance := NewDynamoRPCClient(coordinator.Address + ":" + coordinator.Port)
+	if clientInstance.RpcConnect() != nil {
+		log.Println("forward to", coordinator.Address+":"+coordinator.Port, "failed, coordinating locally")
+		return false, nil
+	}
+	defer clientInstance.CleanConn()
+	// the write may have reached the coordinator, coordinating it again here would make a sibling of it
+	err := clientInstance.rpcConn.Call("MyDynamo.PutWithOptions", args, result)
+	if err != nil {
+		return true, serverError(err)
+	}
+	return true, nil
+}
+
+// Put a file to this server and w - 1 other servers among replicas, along with the
+// index terms and metadata in info
+func (s *DynamoServer) put(value PutArgs, info versionInfo, w int, replicas []DynamoNode, result *PutResult) error {
+	// first put to local storage, on a copy so the caller's clock is left untouched
+	value.Context.Clock = s.stamp(value.Key, value.Context.Clock)
+	// compress once, the same payload goes to every replica
+	local := s.compressPut(IndexedPutArgs{
+		Value:    value,
+		Indexes:  info.indexes,
+		Metadata: assignMetadata(info.metadata, value),
+	})
 	var res bool
-	err := s.PutLocal(value, &res)
+	err := s.PutLocalIndexed(local, &res)
 	if err != nil {
 		return err
 	}
@@ -112,14 +320,14 @@ func (s *DynamoServer) Put(value PutArgs, result *bool) error {
 
 	// then replicate to w - 1 servers, server don't response?
 	cnt := 0
-	for i := 0; i < len(s.preferenceList); i++ {
+	for i := 0; i < len(replicas); i++ {
 		// reached quarom, break
-		if cnt == s.wValue-1 {
+		if cnt == w-1 {
 			break
 		}
 
 		// meet self, skip
-		node := s.preferenceList[i]
+		node := replicas[i]
 		if node.Address == s.selfNode.Address && node.Port == s.selfNode.Port {
 			continue
 		}
@@ -127,20 +335,73 @@ func (s *DynamoServer) Put(value PutArgs, result *bool) error {
 		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
 		err := clientInstance.RpcConnect()
 		if err == nil {
-			succ := clientInstance.PutLocal(value)
-			log.Println("put on other node: ", succ, node.Address, node.Port, cnt, s.wValue)
+			succ := clientInstance.PutLocalIndexed(local)
+			log.Println("put on other node: ", succ, node.Address, node.Port, cnt, w)
 			if !succ {
 				continue
 			}
-			
+
 			cnt++
 		}
 	}
-	log.Println("put res: ", cnt == s.wValue-1, cnt)
-	*result = (cnt == s.wValue-1)
+	log.Println("put res: ", cnt == w-1, cnt)
+
+	acked := cnt
+	if res {
+		acked++
+	}
+	*result = PutResult{
+		Success:     cnt == w-1,
+		Context:     value.Context,
+		Replicas:    acked,
+		Siblings:    s.siblings(value.Key),
+		Coordinator: s.selfNode,
+	}
 	return nil
 }
 
+//Returns a copy of a client's clock for a new version of key coordinated by this node,
+//timestamped by this node's hybrid logical clock. In buckets using dotted version vectors
+//the client's clock becomes the causal context of a new dot; otherwise it is incremented.
+//Clocks over the prune threshold lose their least recently updated entries
+func (s *DynamoServer) stamp(key string, clock VectorClock) VectorClock {
+	bucket, _ := splitStorageKey(key)
+	if s.bucket(bucket).Causality == CAUSALITY_DVV {
+		clock = clock.full()
+		clock.Dot = Dot{NodeID: s.nodeID, Counter: s.nextDot(key, clock)}
+	} else {
+		clock = clock.Copy()
+		clock.Increment(s.nodeID)
+	}
+	if pruned := clock.Prune(s.clockThreshold); pruned > 0 {
+		log.Println(DYNAMO_SERVER, "pruned", pruned, "vector clock entries of", key)
+		atomic.AddInt64(&s.counters.clockTruncations, int64(pruned))
+	}
+	clock.Timestamp = s.hlc.Now()
+	clock.Writer = s.nodeID
+	return clock
+}
+
+//Returns a counter for a new dot of this node on key, above every counter of this
+//node known from the client's context or the versions stored here, and above every
+//counter handed out before, so that concurrent writes never share a dot
+func (s *DynamoServer) nextDot(key string, context VectorClock) int {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	counter := context.VectorClock[s.nodeID]
+	if s.dots[key] > counter {
+		counter = s.dots[key]
+	}
+	for _, obj := range s.peek(key) {
+		full := obj.Context.Clock.full()
+		if full.VectorClock[s.nodeID] > counter {
+			counter = full.VectorClock[s.nodeID]
+		}
+	}
+	s.dots[key] = counter + 1
+	return counter + 1
+}
+
 //Get a file from this server, matched with R other servers
 func (s *DynamoServer) GetLocal(key string, result *DynamoResult) error {
 	if s.crashed {
@@ -148,35 +409,135 @@ func (s *DynamoServer) GetLocal(key string, result *DynamoResult) error {
 		return errors.New("Crashed")
 	}
 
-	objects := s.storage[key]
+	entries, err := decompressEntries(s.stored(key))
+	if err != nil {
+		*result = DynamoResult{}
+		return err
+	}
 	*result = DynamoResult{
-		EntryList: objects,
+		EntryList: entries,
 	}
 
 	return nil
 }
 
+//Get the versions of a key from this server as they are stored, for other servers
+//to read them without decompressing and compressing them again
+func (s *DynamoServer) GetLocalStored(key string, result *StoredResult) error {
+	if s.crashed {
+		*result = StoredResult{}
+		return errors.New("Crashed")
+	}
+	*result = StoredResult{
+		EntryList: s.stored(key),
+	}
+	return nil
+}
+
+//Returns a copy of the healthy versions of key stored on this server, once expired versions
+//are dropped and spilled ones brought back into memory
+func (s *DynamoServer) stored(key string) []StoredEntry {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	s.expire(key)
+	s.load(key)
+	s.verify(key)
+	s.markUsed(key)
+	return append([]StoredEntry{}, s.storage[key]...)
+}
+
+//Returns true if key has concurrent versions stored on this server
+func (s *DynamoServer) siblings(key string) bool {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	return len(s.peek(key)) > 1
+}
+
 //Get a file from this server, matched with R other servers
 func (s *DynamoServer) Get(key string, result *DynamoResult) error {
-	tempRes := DynamoResult{
-		EntryList: []ObjectEntry{},
+	key, err := clientStorageKey(DEFAULT_BUCKET, key)
+	if err != nil {
+		return err
+	}
+	props := s.bucket(DEFAULT_BUCKET)
+	tempRes, _, _, err := s.get(key, props.R, s.replicas(props))
+	if err != nil {
+		return err
+	}
+	*result = tempRes
+	return nil
+}
+
+//Get a file from this server, matched with R other servers. Unless R replicas answered or the
+//caller opted into partial results, only the number of replicas that answered is returned,
+//with QuorumMet false. R is taken from args.Consistency, or the node default when it is unset
+func (s *DynamoServer) GetV2(args GetArgs, result *GetResult) error {
+	key, err := clientStorageKey(args.Bucket, args.Key)
+	if err != nil {
+		return err
 	}
-	err := s.GetLocal(key, &tempRes)
+	props := s.bucket(args.Bucket)
+	r, err := readQuorum(args.Consistency, props)
+	if err != nil {
+		return err
+	}
+	tempRes, replicas, freshest, err := s.get(key, r, s.replicas(props))
 	if err != nil {
 		return err
 	}
 
+	quorumMet := replicas >= r
+	if !quorumMet && !args.AllowPartial {
+		// net/rpc drops the reply of a failed call, so the count goes back in a successful one
+		*result = GetResult{Replicas: replicas}
+		return nil
+	}
+	*result = GetResult{
+		EntryList:   tempRes.EntryList,
+		Context:     CombineContexts(tempRes.EntryList),
+		Replicas:    replicas,
+		QuorumMet:   quorumMet,
+		Coordinator: freshest,
+	}
+	return nil
+}
+
+//Reads key locally and from up to r - 1 other servers among replicas, returning the
+//reconciled versions, the number of replicas, including this one, that answered and
+//the first replica to answer with the freshest versions
+func (s *DynamoServer) get(key string, r int, replicas []DynamoNode) (DynamoResult, int, DynamoNode, error) {
+	return s.read(key, r, replicas, nil)
+}
+
+//Reads key as get does. Unless metadata is nil, it is filled with the metadata of
+//every version read, by versionID
+func (s *DynamoServer) read(key string, r int, replicas []DynamoNode, metadata map[string]ObjectMetadata) (DynamoResult, int, DynamoNode, error) {
+	localRes := DynamoResult{}
+	err := s.GetLocal(key, &localRes)
+	if err != nil {
+		return DynamoResult{}, 0, DynamoNode{}, err
+	}
+	if metadata != nil {
+		collectMetadata(metadata, s.withMetadata(key, localRes.EntryList))
+	}
+	freshest := s.selfNode
+	freshestEntries := localRes.EntryList
+
+	// copy the local versions, reconciliation must not reorder our storage
+	tempRes := DynamoResult{
+		EntryList: append([]ObjectEntry{}, localRes.EntryList...),
+	}
+
 	// make call to r - 1 servers
-	// then replicate to w - 1 servers, server don't response?
 	cnt := 0
-	for i := 0; i < len(s.preferenceList); i++ {
+	for i := 0; i < len(replicas); i++ {
 		// reached quarom, break
-		if cnt == s.rValue-1 {
+		if cnt == r-1 {
 			break
 		}
 
 		// skip over self
-		node := s.preferenceList[i]
+		node := replicas[i]
 		if node.Address == s.selfNode.Address && node.Port == s.selfNode.Port {
 			continue
 		}
@@ -184,43 +545,114 @@ func (s *DynamoServer) Get(key string, result *DynamoResult) error {
 		clientInstance := NewDynamoRPCClient(node.Address + ":" + node.Port)
 		err := clientInstance.RpcConnect()
 		if err == nil {
-			otherResult := clientInstance.GetLocal(key)
-			
+			var otherResult *DynamoResult
+			if metadata == nil {
+				otherResult = plainResult(clientInstance.GetLocalStored(key))
+			} else if entries := clientInstance.GetLocalV2(key); entries != nil {
+				collectMetadata(metadata, entries)
+				otherResult = &DynamoResult{EntryList: plainEntries(entries)}
+			}
+
 			// get fail, continue
 			if otherResult == nil {
 				continue
 			}
 			cnt++
-			log.Println("get other result", *otherResult, node.Address +":" + node.Port)
-			for _, otherObj := range otherResult.EntryList {
-				bigger := false
-				concur := true
-				for idx := 0; idx < len(tempRes.EntryList); idx++ {
-					obj := tempRes.EntryList[idx]
-
-					if obj.Context.Clock.LessThan(otherObj.Context.Clock) {
-						bigger = true
-						tempRes.EntryList = remove(tempRes.EntryList, idx)
-						idx--
-					}
-
-					if !obj.Context.Clock.Concurrent(otherObj.Context.Clock) || 
-					   obj.Context.Clock.Equals(otherObj.Context.Clock){
-						concur = false
-					}
-
-					if otherObj.Context.Clock.LessThan(obj.Context.Clock) {
-						continue
-					}
-				}
-
-				if bigger || concur {
-					tempRes.EntryList = append(tempRes.EntryList, otherObj)
-				}
+			log.Println("get other result", *otherResult, node.Address+":"+node.Port)
+			tempRes.EntryList = reconcile(tempRes.EntryList, otherResult.EntryList)
+
+			// replicas answer in turn, a later one must be strictly fresher to take over
+			if fresher(otherResult.EntryList, freshestEntries) {
+				freshest = node
+				freshestEntries = otherResult.EntryList
 			}
 		}
 	}
-	*result = tempRes
+	reconciled := tempRes.EntryList
+	tempRes.EntryList = s.resolve(key, reconciled)
+	if metadata != nil {
+		for _, entry := range tempRes.EntryList {
+			if _, found := metadata[versionID(entry.Context.Clock)]; !found {
+				metadata[versionID(entry.Context.Clock)] = mergedMetadata(reconciled, metadata, entry)
+			}
+		}
+	}
+	return tempRes, cnt + 1, freshest, nil
+}
+
+//Returns true if a replica holding entries is more up to date than one holding others:
+//it holds some versions where the other has none, its versions descend from the other's,
+//or they are concurrent and it holds the most recent write
+func fresher(entries []ObjectEntry, others []ObjectEntry) bool {
+	if len(entries) == 0 || len(others) == 0 {
+		return len(others) == 0 && len(entries) > 0
+	}
+	clock := CombineContexts(entries).Clock
+	otherClock := CombineContexts(others).Clock
+	return otherClock.LessThan(clock) || (clock.Concurrent(otherClock) && clock.After(otherClock))
+}
+
+//Merges the versions read from another replica into list, dropping versions
+//that are causally older and keeping concurrent ones as siblings
+func reconcile(list []ObjectEntry, others []ObjectEntry) []ObjectEntry {
+	for _, otherObj := range others {
+		bigger := false
+		concur := true
+		for idx := 0; idx < len(list); idx++ {
+			obj := list[idx]
+
+			if obj.Context.Clock.LessThan(otherObj.Context.Clock) {
+				bigger = true
+				list = remove(list, idx)
+				idx--
+			}
+
+			if !obj.Context.Clock.Concurrent(otherObj.Context.Clock) ||
+				obj.Context.Clock.Equals(otherObj.Context.Clock) {
+				concur = false
+			}
+		}
+
+		if bigger || concur {
+			list = append(list, otherObj)
+		}
+	}
+	return list
+}
+
+// Put a value whose context is given as an opaque token
+func (s *DynamoServer) PutWithToken(value TokenPutArgs, result *bool) error {
+	context := NewContext(NewVectorClock())
+	if value.Context != "" {
+		var err error
+		context, err = DecodeContextToken(value.Context, s.contextKey)
+		if err != nil {
+			*result = false
+			return err
+		}
+	}
+	return s.Put(NewPutArgs(value.Key, context, value.Value), result)
+}
+
+// Get a value, with the context of each version encoded as an opaque token
+func (s *DynamoServer) GetWithToken(key string, result *TokenResult) error {
+	var res DynamoResult
+	err := s.Get(key, &res)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]TokenEntry, 0, len(res.EntryList))
+	for _, entry := range res.EntryList {
+		entries = append(entries, TokenEntry{
+			Context: EncodeContextToken(entry.Context, s.contextKey),
+			Value:   entry.Value,
+		})
+	}
+	*result = TokenResult{
+		Context:   EncodeContextToken(CombineContexts(res.EntryList), s.contextKey),
+		EntryList: entries,
+	}
 	return nil
 }
 
@@ -237,7 +669,22 @@ func NewDynamoServer(w int, r int, hostAddr string, hostPort string, id string)
 		preferenceList: preferenceList,
 		selfNode:       selfNodeInfo,
 		nodeID:         id,
-		storage: 	make(map[string][]ObjectEntry),
+		storage:        make(map[string][]StoredEntry),
+		buckets:        make(map[string]BucketProps),
+		bucketLock:     new(sync.RWMutex),
+		expiry:         make(map[string]time.Time),
+		casLock:        new(sync.Mutex),
+		storeLock:      new(sync.Mutex),
+		hlc:            NewHybridClock(),
+		counters:       new(serverCounters),
+		index:          newKeyIndex(),
+		postings:       newKeyIndex(),
+		versions:       make(map[string]map[string]versionInfo),
+		keyTerms:       make(map[string][]string),
+		dots:           make(map[string]int),
+		chunks:         newChunkStore(),
+		damaged:        make(map[string]int),
+		spill:          newSpillTier(),
 	}
 }
 
@@ -258,6 +705,30 @@ func ServeDynamoServer(dynamoServer DynamoServer) error {
 	}
 
 	log.Println(DYNAMO_SERVER, "Successfully Listening to Target Port ", dynamoServer.selfNode.Address+":"+dynamoServer.selfNode.Port)
+
+	if dynamoServer.jsonRPCPort != "" {
+		jsonListener, e := net.Listen("tcp", dynamoServer.selfNode.Address+":"+dynamoServer.jsonRPCPort)
+		if e != nil {
+			log.Println(DYNAMO_SERVER, "Server Can't start During JSON-RPC Port Listening")
+			return e
+		}
+		log.Println(DYNAMO_SERVER, "Serving JSON-RPC on ", dynamoServer.selfNode.Address+":"+dynamoServer.jsonRPCPort)
+		go ServeJSONRPC(jsonListener, rpcServer)
+	}
+
+	if dynamoServer.httpPort != "" {
+		restListener, e := net.Listen("tcp", dynamoServer.selfNode.Address+":"+dynamoServer.httpPort)
+		if e != nil {
+			log.Println(DYNAMO_SERVER, "Server Can't start During REST Port Listening")
+			return e
+		}
+		log.Println(DYNAMO_SERVER, "Serving REST gateway on ", dynamoServer.selfNode.Address+":"+dynamoServer.httpPort)
+		go ServeREST(restListener, &dynamoServer)
+	}
+	if dynamoServer.scrubInterval > 0 {
+		go dynamoServer.scrubLoop()
+	}
+	go dynamoServer.expiryLoop()
 	log.Println(DYNAMO_SERVER, "Serving Server Now")
 
 	return http.Serve(l, rpcServer)
@@ -0,0 +1,100 @@
+package mydynamo
+
+import (
+	"errors"
+	"log"
+)
+
+var ErrStaleRead = errors.New("read is causally older than a version seen by the session")
+
+//A client-side session giving read-your-writes and monotonic reads for its own requests,
+//whichever node they go through. The session remembers the highest context it has seen
+//for each key; a read that does not cover it is retried at CONSISTENCY_ALL and rejected
+//with ErrStaleRead if still stale. Writes are sent to the replica that served the last read
+//of the key, as in the Dynamo paper. Other clients' requests are unaffected
+type Session struct {
+	client       *RPCClient
+	contexts     map[string]VectorClock //Highest clock written or read by this session, by storage key
+	coordinators map[string]DynamoNode  //Replica that served the freshest versions of the last read, by storage key
+}
+
+//Creates a session sending requests through client
+func NewSession(client *RPCClient) *Session {
+	return &Session{
+		client:       client,
+		contexts:     make(map[string]VectorClock),
+		coordinators: make(map[string]DynamoNode),
+	}
+}
+
+//Sends the following requests through client, keeping the versions seen so far
+func (session *Session) SetClient(client *RPCClient) {
+	session.client = client
+}
+
+//Returns the highest context the session has seen for key, to be passed to the next write
+func (session *Session) Context(bucket string, key string) Context {
+	clock, ok := session.contexts[storageKey(bucket, key)]
+	if !ok {
+		return NewContext(NewVectorClock())
+	}
+	return NewContext(clock.Copy())
+}
+
+//Puts a value and remembers the context of the version written. Unless args.Coordinator
+//is set, the write is coordinated by the replica that served the last read of the key
+func (session *Session) Put(args PutOptions) *PutResult {
+	if args.Coordinator == (DynamoNode{}) {
+		args.Coordinator = session.coordinators[storageKey(args.Bucket, args.Key)]
+	}
+	result := session.client.PutWithOptions(args)
+	if result != nil && result.Success {
+		session.observe(args.Bucket, args.Key, result.Context, result.Coordinator)
+	}
+	return result
+}
+
+//Gets a value, making sure it is at least as recent as every version of the key this
+//session wrote or read before. Returns ErrStaleRead, along with the stale result,
+//when even a read from all replicas does not cover them
+func (session *Session) Get(args GetArgs) (*GetResult, error) {
+	result, err := session.client.GetV2(args)
+	if err != nil {
+		return nil, err
+	}
+	if !session.covers(args.Bucket, args.Key, result.Context) {
+		log.Println("stale read of", args.Key, "retrying from all replicas")
+		args.Consistency = Consistency{Level: CONSISTENCY_ALL}
+		args.AllowPartial = true
+		result, err = session.client.GetV2(args)
+		if err != nil {
+			return nil, err
+		}
+		if !session.covers(args.Bucket, args.Key, result.Context) {
+			return result, ErrStaleRead
+		}
+	}
+	session.observe(args.Bucket, args.Key, result.Context, result.Coordinator)
+	return result, nil
+}
+
+//Returns true if context is equal to or causally descended from the highest context seen for key
+func (session *Session) covers(bucket string, key string, context Context) bool {
+	seen, ok := session.contexts[storageKey(bucket, key)]
+	if !ok {
+		return true
+	}
+	return seen.Equals(context.Clock) || seen.LessThan(context.Clock)
+}
+
+//Records that the session has seen context for key, served by coordinator
+func (session *Session) observe(bucket string, key string, context Context, coordinator DynamoNode) {
+	sessionKey := storageKey(bucket, key)
+	session.coordinators[sessionKey] = coordinator
+	clock, ok := session.contexts[sessionKey]
+	if !ok {
+		clock = NewVectorClock()
+	}
+	clock.Combine([]VectorClock{context.Clock})
+	session.contexts[sessionKey] = clock
+}
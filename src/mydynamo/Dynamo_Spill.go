@@ -0,0 +1,414 @@
+package mydynamo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrStorageFull = errors.New("memory budget exceeded and no disk space left to spill to")
+
+//Memory budget of a node and the disk tier its cold keys are spilled to.
+//It changes along with storage, under storeLock; lock lets Stats read it on its own
+type spillTier struct {
+	lock       *sync.Mutex
+	budget     int64            //Bytes of keys, values, clocks, records and chunks kept in memory, 0 for no limit
+	used       int64            //Bytes of the keys and chunks in memory
+	sizes      map[string]int64 //Bytes of each key and chunkName in memory
+	lastUse    map[string]int64 //Tick at which each key and chunkName in memory was last read or written
+	tick       int64
+	dir        string           //Directory of the spill files, empty when spilling is disabled
+	diskBudget int64            //Bytes of spill files allowed in dir, 0 for no limit
+	diskUsed   int64            //Bytes of the spill files
+	spilled    map[string]int64 //Size of the spill file of each spilled key
+}
+
+//Creates a tier without a memory budget
+func newSpillTier() *spillTier {
+	return &spillTier{
+		lock:    &sync.Mutex{},
+		sizes:   make(map[string]int64),
+		lastUse: make(map[string]int64),
+		spilled: make(map[string]int64),
+	}
+}
+
+//Caps the bytes of keys, values, clocks, version records and chunks this server keeps in memory.
+//Past the budget, the least recently used keys and chunks are spilled to disk; 0, the default,
+//keeps everything in memory
+func (s *DynamoServer) SetMemoryBudget(budget int64) {
+	s.spill.budget = budget
+}
+
+//Spills cold keys to a directory of their own under dir, holding up to diskBudget bytes
+//(0 for no limit). Without a spill directory, writes past the memory budget are rejected.
+//Files a previous process left there are removed: their records did not survive it
+func (s *DynamoServer) SetSpillDir(dir string, diskBudget int64) {
+	s.spill.dir = filepath.Join(dir, s.nodeID)
+	s.spill.diskBudget = diskBudget
+	if err := os.RemoveAll(s.spill.dir); err != nil {
+		log.Println(DYNAMO_SERVER, "failed to clear spill directory", err)
+	}
+}
+
+//Returns the bytes a key's versions take in memory: the key, then each value and clock
+func keySize(key string, entries []StoredEntry) int64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	size := int64(len(key))
+	for _, entry := range entries {
+		size += int64(len(entry.Value) + len(versionID(entry.Context.Clock)))
+	}
+	return size
+}
+
+//Returns the bytes the records of a key's versions take in memory: their IDs, metadata
+//and index terms, the terms counted twice as each is also an entry of the local index
+func recordsSize(key string, infos map[string]versionInfo) int64 {
+	_, clientKey := splitStorageKey(key)
+	size := int64(0)
+	for id, info := range infos {
+		size += int64(len(id) + len(info.metadata.ContentType))
+		for name, value := range info.metadata.UserMetadata {
+			size += int64(len(name) + len(value))
+		}
+		for _, term := range info.indexes {
+			size += int64(len(term.Index) + len(term.Value) + 8 + len(indexEntry(term, clientKey)))
+		}
+	}
+	return size
+}
+
+//Returns the bytes of memory taken by key with entries in storage and infos recording its versions.
+//The records of a spilled key stay in memory
+func memorySize(key string, entries []StoredEntry, infos map[string]versionInfo) int64 {
+	return keySize(key, entries) + recordsSize(key, infos)
+}
+
+//Brings the memory accounting of key up to date with its versions in storage and their records
+func (s *DynamoServer) account(key string) {
+	s.spill.setSize(key, memorySize(key, s.storage[key], s.versions[key]))
+}
+
+//Records that name, a storage key or a chunkName, takes size bytes of memory, marking it as just used
+func (t *spillTier) setSize(name string, size int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.used += size - t.sizes[name]
+	if size == 0 {
+		delete(t.sizes, name)
+		delete(t.lastUse, name)
+		return
+	}
+	t.sizes[name] = size
+	t.tick++
+	t.lastUse[name] = t.tick
+}
+
+//Returns the bytes of memory accounted to key
+func (s *DynamoServer) accounted(key string) int64 {
+	s.spill.lock.Lock()
+	defer s.spill.lock.Unlock()
+	return s.spill.sizes[key]
+}
+
+//Returns the name under which the chunk id is accounted and spilled. No storage key starts
+//with two separators: those of named buckets start with one followed by the bucket name
+func chunkName(id string) string {
+	return BUCKET_SEPARATOR + BUCKET_SEPARATOR + id
+}
+
+//Returns the chunk ID of a name returned by chunkName, false for storage keys
+func chunkOf(name string) (string, bool) {
+	if !strings.HasPrefix(name, BUCKET_SEPARATOR+BUCKET_SEPARATOR) {
+		return "", false
+	}
+	return name[2*len(BUCKET_SEPARATOR):], true
+}
+
+//Marks a key in memory as just read, making it the last to be spilled
+func (s *DynamoServer) markUsed(key string) {
+	t := s.spill
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, found := t.sizes[key]; found {
+		t.tick++
+		t.lastUse[key] = t.tick
+	}
+}
+
+//Returns the path of the spill file of key
+func (t *spillTier) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:]))
+}
+
+//Returns true if key is spilled to disk
+func (s *DynamoServer) isSpilled(key string) bool {
+	s.spill.lock.Lock()
+	defer s.spill.lock.Unlock()
+	_, found := s.spill.spilled[key]
+	return found
+}
+
+//Encodes the versions of a key for its spill file: their clocks as a VectorClockEncoder
+//stream, each followed by the uvarint-prefixed codec, the checksum and the uvarint-prefixed value
+func encodeSpill(entries []StoredEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := NewVectorClockEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry.Context.Clock); err != nil {
+			return nil, err
+		}
+		record := binary.AppendUvarint(nil, uint64(len(entry.Codec)))
+		record = append(record, entry.Codec...)
+		record = binary.AppendUvarint(record, uint64(entry.Checksum))
+		record = binary.AppendUvarint(record, uint64(len(entry.Value)))
+		buf.Write(record)
+		buf.Write(entry.Value)
+	}
+	return buf.Bytes(), nil
+}
+
+//Reads a uvarint-prefixed field of a spill file of limit bytes
+func readSpillField(r *bufio.Reader, limit int) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > uint64(limit) {
+		return nil, ErrInvalidClockEncoding
+	}
+	field := make([]byte, size)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, ErrInvalidClockEncoding
+	}
+	return field, nil
+}
+
+//Decodes a spill file written by encodeSpill
+func decodeSpill(data []byte) ([]StoredEntry, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	decoder := NewVectorClockDecoder(r)
+	entries := make([]StoredEntry, 0)
+	for {
+		var clock VectorClock
+		err := decoder.Decode(&clock)
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		codec, err := readSpillField(r, len(data))
+		if err != nil {
+			return nil, err
+		}
+		checksum, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrInvalidClockEncoding
+		}
+		value, err := readSpillField(r, len(data))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, StoredEntry{
+			Context:  NewContext(clock),
+			Value:    value,
+			Codec:    string(codec),
+			Checksum: uint32(checksum),
+		})
+	}
+}
+
+//Moves the versions of key from memory to its spill file.
+//Fails with ErrStorageFull if there is no spill directory or no room left in it
+func (s *DynamoServer) spillKey(key string) error {
+	data, err := encodeSpill(s.storage[key])
+	if err != nil {
+		return err
+	}
+	if err := s.writeSpill(key, data); err != nil {
+		return err
+	}
+	delete(s.storage, key)
+	s.account(key)
+	return nil
+}
+
+//Moves a chunk from memory to its spill file, failing as spillKey does
+func (s *DynamoServer) spillChunk(id string) error {
+	if err := s.writeSpill(chunkName(id), s.chunks.data[id]); err != nil {
+		return err
+	}
+	delete(s.chunks.data, id)
+	s.spill.setSize(chunkName(id), 0)
+	return nil
+}
+
+//Writes the spill file of name, a storage key or a chunkName
+func (s *DynamoServer) writeSpill(name string, data []byte) error {
+	t := s.spill
+	if t.dir == "" {
+		return ErrStorageFull
+	}
+	t.lock.Lock()
+	full := t.diskBudget > 0 && t.diskUsed+int64(len(data)) > t.diskBudget
+	t.lock.Unlock()
+	if full {
+		return ErrStorageFull
+	}
+
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		log.Println(DYNAMO_SERVER, "failed to create spill directory", err)
+		return ErrStorageFull
+	}
+	if err := ioutil.WriteFile(t.path(name), data, 0600); err != nil {
+		log.Println(DYNAMO_SERVER, "failed to spill", name, err)
+		os.Remove(t.path(name))
+		return ErrStorageFull
+	}
+	t.lock.Lock()
+	t.spilled[name] = int64(len(data))
+	t.diskUsed += int64(len(data))
+	t.lock.Unlock()
+	return nil
+}
+
+//Deletes the spill file of key, a storage key or a chunkName, if it is spilled
+func (s *DynamoServer) unspill(key string) {
+	t := s.spill
+	t.lock.Lock()
+	size, found := t.spilled[key]
+	if found {
+		delete(t.spilled, key)
+		t.diskUsed -= size
+	}
+	t.lock.Unlock()
+	if found {
+		os.Remove(t.path(key))
+	}
+}
+
+//Reads the versions of a spilled key without bringing them back into memory
+func (s *DynamoServer) readSpill(key string) ([]StoredEntry, error) {
+	data, err := ioutil.ReadFile(s.spill.path(key))
+	if err != nil {
+		return nil, err
+	}
+	return decodeSpill(data)
+}
+
+//Returns the versions of key, from memory or from its spill file, leaving them where they are.
+//Callers hold storeLock
+func (s *DynamoServer) peek(key string) []StoredEntry {
+	if !s.isSpilled(key) {
+		return s.storage[key]
+	}
+	entries, err := s.readSpill(key)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "failed to read spilled key", key, err)
+		return nil
+	}
+	return entries
+}
+
+//Brings a spilled key back into memory, spilling colder keys if that exceeds the budget.
+//A spill file that cannot be read is treated as corrupt, for the scrubber to repair.
+//Callers hold storeLock
+func (s *DynamoServer) load(key string) {
+	if !s.isSpilled(key) {
+		return
+	}
+	entries, err := s.readSpill(key)
+	s.unspill(key)
+	if err != nil {
+		log.Println(DYNAMO_SERVER, "dropping unreadable spill file of", key, err)
+		s.index.remove(key)
+		s.updateVersions(key, nil, nil)
+		s.account(key)
+		s.markDamaged(key, 1)
+		return
+	}
+	s.storage[key] = entries
+	s.account(key)
+	// reads must not fail for lack of memory, the budget is restored on later writes otherwise
+	s.makeRoom(key, 0)
+}
+
+//Makes room in memory for size more bytes of key by spilling the least recently used
+//other keys and chunks, down to 90% of the budget so that spills are not needed on every write.
+//Fails with ErrStorageFull if the keys that fit on disk do not free enough memory.
+//Callers hold storeLock
+func (s *DynamoServer) makeRoom(key string, size int64) error {
+	t := s.spill
+	t.lock.Lock()
+	if t.budget <= 0 || t.used+size <= t.budget {
+		t.lock.Unlock()
+		return nil
+	}
+	target := t.budget*9/10 - size
+	cold := make([]string, 0, len(t.lastUse))
+	for other := range t.lastUse {
+		if other != key {
+			cold = append(cold, other)
+		}
+	}
+	sort.Slice(cold, func(i, j int) bool {
+		return t.lastUse[cold[i]] < t.lastUse[cold[j]]
+	})
+	t.lock.Unlock()
+
+	for _, other := range cold {
+		t.lock.Lock()
+		done := t.used <= target
+		t.lock.Unlock()
+		if done {
+			break
+		}
+		var err error
+		if id, ok := chunkOf(other); ok {
+			err = s.spillChunk(id)
+		} else if _, inMemory := s.storage[other]; inMemory {
+			err = s.spillKey(other)
+		} else {
+			// spilled already, only its records are left in memory
+			continue
+		}
+		if err != nil {
+			// a smaller one may still fit on disk
+			continue
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.used+size > t.budget {
+		return ErrStorageFull
+	}
+	return nil
+}
+
+//Returns every storage key of this server, in memory or spilled. Callers hold storeLock
+func (s *DynamoServer) storedKeys() []string {
+	keys := make([]string, 0, len(s.storage))
+	for key := range s.storage {
+		keys = append(keys, key)
+	}
+	s.spill.lock.Lock()
+	defer s.spill.lock.Unlock()
+	for key := range s.spill.spilled {
+		if _, ok := chunkOf(key); !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,38 @@
+package mydynamo
+
+import "sync/atomic"
+
+//Counters of a node, updated atomically as requests are served
+type serverCounters struct {
+	clockTruncations   int64 //Vector clock entries dropped by Prune
+	compressionInput   int64 //Bytes of values passed to compressEntry
+	compressionOutput  int64 //Bytes of the values compressEntry returned
+	corruptionDetected int64 //Corrupt versions dropped from storage or rejected on receipt
+	corruptionRepaired int64 //Dropped versions replaced by the scrubber
+}
+
+//Reports this node's counters
+func (s *DynamoServer) Stats(_ Empty, result *ServerStats) error {
+	*result = ServerStats{
+		ClockTruncations:   atomic.LoadInt64(&s.counters.clockTruncations),
+		CompressionInput:   atomic.LoadInt64(&s.counters.compressionInput),
+		CompressionOutput:  atomic.LoadInt64(&s.counters.compressionOutput),
+		CorruptionDetected: atomic.LoadInt64(&s.counters.corruptionDetected),
+		CorruptionRepaired: atomic.LoadInt64(&s.counters.corruptionRepaired),
+	}
+	s.spill.lock.Lock()
+	result.MemoryUsed = s.spill.used
+	result.DiskUsed = s.spill.diskUsed
+	for name := range s.spill.spilled {
+		if _, ok := chunkOf(name); ok {
+			result.SpilledChunks++
+		} else {
+			result.SpilledKeys++
+		}
+	}
+	s.spill.lock.Unlock()
+	if result.CompressionOutput > 0 {
+		result.CompressionRatio = float64(result.CompressionInput) / float64(result.CompressionOutput)
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package mydynamo
 
+import "time"
+
 //Placeholder type for RPC functions that don't need an argument list or a return value
 type Empty struct{}
 
@@ -31,3 +33,303 @@ type PutArgs struct {
 	Context Context
 	Value   []byte
 }
+
+//Arguments for a Put whose context is an opaque token, see EncodeContextToken
+type TokenPutArgs struct {
+	Key     string
+	Context string //Token from a previous Get, empty for a fresh write
+	Value   []byte
+}
+
+//A single value, with its Context encoded as an opaque token
+type TokenEntry struct {
+	Context string
+	Value   []byte
+}
+
+//Result of a GetWithToken operation
+type TokenResult struct {
+	Context   string //Token covering every entry, to be passed to the next Put
+	EntryList []TokenEntry
+}
+
+//Result of a PutV2 operation
+type PutResult struct {
+	Success     bool       //True if the write reached W replicas
+	Context     Context    //Context of the version that was written, to continue a causal chain
+	Replicas    int        //Number of replicas, including the coordinator, that stored the version
+	Siblings    bool       //True if the coordinator now holds concurrent versions of the key
+	Coordinator DynamoNode //Node that coordinated the write
+}
+
+//Arguments for a GetV2 operation
+type GetArgs struct {
+	Bucket       string //Bucket holding the key, DEFAULT_BUCKET when empty
+	Key          string
+	AllowPartial bool //Return the versions read even if fewer than R replicas answered
+	Consistency  Consistency
+}
+
+//Result of a GetV2 operation
+type GetResult struct {
+	EntryList   []ObjectEntry
+	Context     Context    //Context covering every entry, to be passed to the next Put
+	Replicas    int        //Number of replicas, including the coordinator, that answered
+	QuorumMet   bool       //True if at least R replicas answered
+	Coordinator DynamoNode //Replica that answered first with the freshest versions, the preferred coordinator of the next Put
+}
+
+//Arguments for a PutWithOptions operation
+type PutOptions struct {
+	Bucket       string //Bucket holding the key, DEFAULT_BUCKET when empty
+	Key          string
+	Context      Context
+	Value        []byte
+	Consistency  Consistency
+	Indexes      []IndexTerm       //Terms the version is indexed under, see IndexQuery
+	ContentType  string            //Media type of the value, returned by GetV3
+	UserMetadata map[string]string //Arbitrary client metadata of the version, returned by GetV3
+	Coordinator  DynamoNode        //Node the write is forwarded to, usually GetResult.Coordinator; the receiving node coordinates when unset or unreachable
+	Delete       bool              //Write a tombstone that marks the key deleted, reported by GetV3 in ObjectMetadata.Deleted
+}
+
+//Arguments for a MultiGet operation
+type MultiGetArgs struct {
+	Bucket       string
+	Keys         []string
+	AllowPartial bool //Return the versions read even if fewer than R replicas answered
+	Consistency  Consistency
+}
+
+//Result of a single key of a MultiGet operation
+type KeyGetResult struct {
+	Key    string
+	Result GetResult
+	Err    string //Why the key could not be read, empty on success
+}
+
+//Result of a MultiGet operation, in the order of MultiGetArgs.Keys
+type MultiGetResult struct {
+	Results []KeyGetResult
+}
+
+//Arguments for a MultiPut operation
+type MultiPutArgs struct {
+	Bucket      string
+	Values      []PutArgs
+	Consistency Consistency
+}
+
+//Result of a single key of a MultiPut operation
+type KeyPutResult struct {
+	Key    string
+	Result PutResult
+	Err    string //Why the value could not be written, empty on success
+}
+
+//Result of a MultiPut operation, in the order of MultiPutArgs.Values
+type MultiPutResult struct {
+	Results []KeyPutResult
+}
+
+//Result of a single key of a GetLocalBatch operation
+type LocalGetResult struct {
+	Result DynamoResult
+	Err    string //Why the key could not be read, empty on success
+}
+
+//Result of a single value of a PutLocalBatch operation
+type LocalPutResult struct {
+	Success bool
+	Err     string //Why the value could not be written, empty on success
+}
+
+//Result of a ConditionalPut operation
+type ConditionalPutResult struct {
+	Result   PutResult
+	Conflict bool          //True if the stored versions did not match the given context
+	Current  []ObjectEntry //Versions stored across the write quorum, set on a conflict
+}
+
+//Arguments for the CRDT operations Increment, AddToSet, RemoveFromSet, SetRegister and Fetch
+type CRDTArgs struct {
+	Bucket      string
+	Key         string
+	Field       string //Field of a CRDT map to operate on, empty to use the value itself
+	Delta       int64  //Amount to add to a counter
+	Element     []byte //Set element, or new register value
+	Consistency Consistency
+}
+
+//Value of a CRDT after an operation
+type CRDTResult struct {
+	Type     CRDTType
+	Counter  int64
+	Set      [][]byte
+	Register []byte
+	Map      map[string]CRDTResult
+	Context  Context //Context of the version read or written
+	Success  bool    //True if the read or write reached its quorum
+}
+
+//Counters reported by a node's Stats RPC
+type ServerStats struct {
+	ClockTruncations   int64   //Vector clock entries dropped because a clock exceeded the prune threshold
+	CompressionInput   int64   //Bytes of values this node compressed for storage or replication
+	CompressionOutput  int64   //Bytes those values took once compressed
+	CompressionRatio   float64 //CompressionInput over CompressionOutput, 0 before any value was compressed
+	CorruptionDetected int64   //Versions dropped from storage or rejected on receipt because they did not match their checksum
+	CorruptionRepaired int64   //Dropped versions since replaced from another replica
+	MemoryUsed         int64   //Bytes of keys, values, clocks, version records and chunks held in memory
+	DiskUsed           int64   //Bytes of the spill files of keys and chunks spilled to disk
+	SpilledKeys        int     //Number of keys spilled to disk
+	SpilledChunks      int     //Number of chunks spilled to disk
+}
+
+//Arguments for Scan, ScanPrefix and ScanLocal operations
+type ScanArgs struct {
+	Bucket       string //Bucket holding the keys, DEFAULT_BUCKET when empty
+	StartKey     string //First key of the range, ignored by ScanPrefix
+	EndKey       string //Key after the range, unbounded when empty; ignored by ScanPrefix
+	Prefix       string //Prefix of the keys returned by ScanPrefix
+	Limit        int    //Maximum number of keys returned, SCAN_DEFAULT_LIMIT when unset
+	Cursor       string //ScanResult.Cursor of the previous page, empty for the first page
+	AllowPartial bool
+	Consistency  Consistency
+}
+
+//A key returned by a scan, with its versions
+type ScanEntry struct {
+	Key       string
+	EntryList []ObjectEntry
+	Context   Context //Context covering every entry, to be passed to the next Put
+}
+
+//Result of a Scan or ScanPrefix operation
+type ScanResult struct {
+	Entries   []ScanEntry //Keys of the page, in order
+	Cursor    string      //Continuation token of the next page, empty after the last page
+	Replicas  int         //Number of replicas, including the coordinator, that answered
+	QuorumMet bool        //True if at least R replicas answered
+}
+
+//Arguments for ListKeys and ListKeysLocal operations
+type ListKeysArgs struct {
+	Bucket     string //Bucket whose keys are listed, unless AllBuckets is set
+	AllBuckets bool   //Lists the keys of every bucket
+	Limit      int    //Maximum number of keys returned, SCAN_DEFAULT_LIMIT when unset
+	Cursor     string //ListKeysResult.Cursor of the previous page, empty for the first page
+}
+
+//A key, along with the bucket holding it
+type BucketKey struct {
+	Bucket string
+	Key    string
+}
+
+//Result of a ListKeys operation
+type ListKeysResult struct {
+	Keys        []BucketKey  //Keys of the page, named buckets by name first, then the default bucket
+	Cursor      string       //Continuation token of the next page, empty after the last page
+	Nodes       int          //Number of nodes, including the coordinator, that answered
+	Unreachable []DynamoNode //Nodes whose keys are missing from the page
+}
+
+//A term a version is indexed under in the secondary index named Index
+type IndexTerm struct {
+	Index   string //Name of the index, e.g. "user_id"
+	Value   string //Term of a string index
+	Int     int64  //Term of an integer index
+	Integer bool   //True for integer indexes
+}
+
+//Arguments for a PutLocalIndexed operation: a version with its index terms and metadata
+type IndexedPutArgs struct {
+	Value    PutArgs
+	Indexes  []IndexTerm
+	Metadata ObjectMetadata //Metadata of the version, the receiving server fills in LastModified and Checksum when unset
+	Codec    string         //Codec Value.Value is compressed with, CODEC_NONE for plain values
+	Checksum uint32         //CRC32C of Value.Value as sent, checked on receipt unless 0
+}
+
+//Arguments for IndexQuery and IndexQueryLocal operations
+type IndexQueryArgs struct {
+	Bucket  string //Bucket holding the keys, DEFAULT_BUCKET when empty
+	Index   string //Name of the index
+	Integer bool   //True to query an integer index
+	Value   string //Term looked up in a string index
+	Min     int64  //Smallest term looked up in an integer index
+	Max     int64  //Largest term looked up in an integer index
+}
+
+//Result of an IndexQuery operation
+type IndexQueryResult struct {
+	Keys        []string     //Matching keys, in order
+	Nodes       int          //Number of nodes, including the coordinator, that answered
+	Unreachable []DynamoNode //Nodes whose keys may be missing
+}
+
+//Metadata stored and replicated along with a version
+type ObjectMetadata struct {
+	ContentType  string            //Media type of the value, as given by the client
+	UserMetadata map[string]string //Arbitrary client metadata
+	LastModified time.Time         //Time the version was written, assigned by its coordinator
+	Checksum     uint32            //CRC32C of the value, see Checksum
+	Deleted      bool              //True if the version is a tombstone, see PutOptions.Delete
+}
+
+//A single version along with its Context and metadata
+type ObjectEntryV2 struct {
+	Context  Context
+	Value    []byte
+	Metadata ObjectMetadata
+}
+
+//A version as servers store it and pass it between each other: its value may be compressed
+type StoredEntry struct {
+	Context  Context
+	Value    []byte
+	Codec    string //Codec Value is compressed with, CODEC_NONE for plain values
+	Checksum uint32 //CRC32C of Value, see Checksum. 0 only on versions from older nodes
+}
+
+//Result of a GetLocalStored operation
+type StoredResult struct {
+	EntryList []StoredEntry
+}
+
+//Result of a GetV3 operation
+type GetResultV3 struct {
+	EntryList   []ObjectEntryV2
+	Context     Context    //Context covering every entry, to be passed to the next Put
+	Replicas    int        //Number of replicas, including the coordinator, that answered
+	QuorumMet   bool       //True if at least R replicas answered
+	Coordinator DynamoNode //Replica that answered first with the freshest versions, the preferred coordinator of the next Put
+}
+
+//Arguments for a PutChunk operation
+type ChunkArgs struct {
+	Bucket      string      //Bucket whose replicas store the chunk, DEFAULT_BUCKET when empty
+	Data        []byte      //Content of the chunk, at most CHUNK_SIZE bytes
+	Consistency Consistency //Number of replicas that must store the chunk, the bucket's W when empty
+}
+
+//Result of a PutChunk operation
+type ChunkResult struct {
+	ID       string //Content address of the chunk, see ChunkID
+	Success  bool   //True if W replicas stored the chunk
+	Replicas int    //Number of replicas, including the coordinator, that stored the chunk
+}
+
+//Arguments for a PushKey operation
+type PushArgs struct {
+	Key  string     //Storage key to send
+	Node DynamoNode //Node the versions are sent to
+}
+
+//Result of a Scrub operation
+type ScrubResult struct {
+	Keys     int //Number of keys checked
+	Corrupt  int //Number of versions found corrupt and dropped
+	Repaired int //Number of dropped versions, from this pass or earlier ones, replaced from another replica
+}
@@ -1,7 +1,7 @@
 package mydynamo
 
-//Removes an element at the specified index from a list of ObjectEntry structs
-func remove(list []ObjectEntry, index int) []ObjectEntry {
+//Removes an element at the specified index from a list of ObjectEntry or StoredEntry structs
+func remove[T ObjectEntry | StoredEntry](list []T, index int) []T {
 	return append(list[:index], list[index+1:]...)
 }
 
@@ -43,3 +43,14 @@ func NewDynamoNode(addr string, port string) DynamoNode {
 		Port:    port,
 	}
 }
+
+//Creates a Context that causally descends from every entry in the list
+func CombineContexts(entries []ObjectEntry) Context {
+	clocks := make([]VectorClock, 0, len(entries))
+	for _, entry := range entries {
+		clocks = append(clocks, entry.Context.Clock)
+	}
+	combined := NewVectorClock()
+	combined.Combine(clocks)
+	return NewContext(combined)
+}
@@ -1,19 +1,81 @@
 package mydynamo
 
+import (
+	"sort"
+	"time"
+)
+
 // VectorClock map of (NodeId, version) [1,0,0] length = number of node cluster
+// When Dot is set the clock is a dotted version vector: Dot identifies the write itself
+// and the map is only the causal context the writer had seen
 type VectorClock struct {
 	VectorClock map[string]int
+	Timestamps  map[string]int64 //Last time (unix nanoseconds) each entry was incremented, used to prune old entries
+	Timestamp   int64            //Hybrid logical clock timestamp of the write that produced this version, see HybridClock
+	Writer      string           //ID of the node that assigned Timestamp, breaks ties between equal timestamps
+	Dot         Dot              //Event of the write, for dotted version vectors
+}
+
+//A single write event: the Counter-th write coordinated by NodeID on a key
+type Dot struct {
+	NodeID  string
+	Counter int
 }
 
 //Creates a new VectorClock
 func NewVectorClock() VectorClock {
 	return VectorClock{
 		VectorClock: make(map[string]int),
+		Timestamps:  make(map[string]int64),
+	}
+}
+
+//Returns a copy of this VectorClock that can be modified independently
+func (s VectorClock) Copy() VectorClock {
+	clock := NewVectorClock()
+	for nodeID, version := range s.VectorClock {
+		clock.VectorClock[nodeID] = version
+	}
+	for nodeID, updated := range s.Timestamps {
+		clock.Timestamps[nodeID] = updated
 	}
+	clock.Timestamp = s.Timestamp
+	clock.Writer = s.Writer
+	clock.Dot = s.Dot
+	return clock
+}
+
+//Returns true if this clock is a dotted version vector
+func (s VectorClock) HasDot() bool {
+	return s.Dot.NodeID != ""
+}
+
+//Returns true if the event dot is part of this clock's history
+func (s VectorClock) Covers(dot Dot) bool {
+	return s.Dot == dot || s.VectorClock[dot.NodeID] >= dot.Counter
+}
+
+//Returns this clock as a plain vector clock, with its dot folded into the map
+func (s VectorClock) full() VectorClock {
+	clock := s.Copy()
+	clock.Dot = Dot{}
+	if s.HasDot() && clock.VectorClock[s.Dot.NodeID] < s.Dot.Counter {
+		clock.VectorClock[s.Dot.NodeID] = s.Dot.Counter
+		clock.Timestamps[s.Dot.NodeID] = HLCTime(s.Timestamp).UnixNano()
+	}
+	return clock
 }
 
 //Returns true if the other VectorClock is causally descended from this one
 func (s VectorClock) LessThan(otherClock VectorClock) bool {
+	// between dotted version vectors, a write is obsolete once the other's context has seen it
+	if s.HasDot() && otherClock.HasDot() {
+		return s.Dot != otherClock.Dot && otherClock.Covers(s.Dot)
+	}
+	if s.HasDot() || otherClock.HasDot() {
+		return s.full().LessThan(otherClock.full())
+	}
+
 	less := false
 	for nodeID, version := range s.VectorClock {
 		if _, ok := otherClock.VectorClock[nodeID]; !ok && version != 0 {
@@ -34,6 +96,15 @@ func (s VectorClock) LessThan(otherClock VectorClock) bool {
 	return less
 }
 
+//Returns true if this VectorClock's write happened after the other's, by timestamp then writer ID.
+//Unlike LessThan this is a total order, used to pick a single winner among concurrent versions
+func (s VectorClock) After(otherClock VectorClock) bool {
+	if s.Timestamp != otherClock.Timestamp {
+		return s.Timestamp > otherClock.Timestamp
+	}
+	return s.Writer > otherClock.Writer
+}
+
 //Returns true if neither VectorClock is causally descended from the other
 func (s VectorClock) Concurrent(otherClock VectorClock) bool {
 	return !s.LessThan(otherClock) && !otherClock.LessThan(s)
@@ -42,22 +113,78 @@ func (s VectorClock) Concurrent(otherClock VectorClock) bool {
 //Increments this VectorClock at the element associated with nodeId
 func (s *VectorClock) Increment(nodeId string) {
 	s.VectorClock[nodeId]++
+	s.touch(nodeId)
+}
+
+//Records that the element associated with nodeId was just updated
+func (s *VectorClock) touch(nodeId string) {
+	if s.Timestamps == nil {
+		s.Timestamps = make(map[string]int64)
+	}
+	s.Timestamps[nodeId] = time.Now().UnixNano()
+}
+
+//Removes the least recently updated elements until at most threshold remain, as in the
+//Dynamo paper. Entries without a timestamp are removed first. Returns the number removed
+func (s *VectorClock) Prune(threshold int) int {
+	if threshold <= 0 || len(s.VectorClock) <= threshold {
+		return 0
+	}
+
+	nodeIDs := make([]string, 0, len(s.VectorClock))
+	for nodeID := range s.VectorClock {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		if s.Timestamps[nodeIDs[i]] != s.Timestamps[nodeIDs[j]] {
+			return s.Timestamps[nodeIDs[i]] < s.Timestamps[nodeIDs[j]]
+		}
+		return nodeIDs[i] < nodeIDs[j]
+	})
+
+	pruned := len(nodeIDs) - threshold
+	for _, nodeID := range nodeIDs[:pruned] {
+		delete(s.VectorClock, nodeID)
+		delete(s.Timestamps, nodeID)
+	}
+	return pruned
 }
 
 //Changes this VectorClock to be causally descended from all VectorClocks in clocks
 func (s *VectorClock) Combine(clocks []VectorClock) {
 	// add non existing key to s
 	for _, clock := range clocks {
+		clock = clock.full()
+		if clock.After(*s) {
+			s.Timestamp = clock.Timestamp
+			s.Writer = clock.Writer
+		}
 		for nodeID, otherVersion := range clock.VectorClock {
 			if version, found := s.VectorClock[nodeID]; !found || (found && version < otherVersion) {
 				s.VectorClock[nodeID] = otherVersion
 			}
 		}
+		for nodeID, updated := range clock.Timestamps {
+			if updated > s.Timestamps[nodeID] {
+				if s.Timestamps == nil {
+					s.Timestamps = make(map[string]int64)
+				}
+				s.Timestamps[nodeID] = updated
+			}
+		}
 	}
 }
 
 //Tests if two VectorClocks are equal
 func (s *VectorClock) Equals(otherClock VectorClock) bool {
+	if s.HasDot() && otherClock.HasDot() {
+		return s.Dot == otherClock.Dot
+	}
+	if s.HasDot() || otherClock.HasDot() {
+		full := s.full()
+		return full.Equals(otherClock.full())
+	}
+
 	for nodeId, version := range s.VectorClock {
 		if otherVersion, found := otherClock.VectorClock[nodeId]; (!found && version != 0) || otherVersion != version {
 			return false
@@ -7,6 +7,7 @@ import (
 	"net/rpc"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -45,6 +46,41 @@ func main() {
 		log.Println(mydynamo.USAGE_STRING)
 		os.Exit(mydynamo.EX_CONFIG)
 	}
+	//Optional settings, disabled when absent from the config file
+	jsonRPCPort := dynamoConfigs.Key(mydynamo.JSON_RPC_PORT).MustInt(0)
+	httpPort := dynamoConfigs.Key(mydynamo.HTTP_PORT).MustInt(0)
+	contextKey := dynamoConfigs.Key(mydynamo.CONTEXT_HMAC_KEY).String()
+	clockThreshold := dynamoConfigs.Key(mydynamo.CLOCK_PRUNE_THRESHOLD).MustInt(0)
+	scrubInterval := dynamoConfigs.Key(mydynamo.SCRUB_INTERVAL).MustInt(0)
+	memoryBudget := dynamoConfigs.Key(mydynamo.MEMORY_BUDGET).MustInt64(0)
+	spillDir := dynamoConfigs.Key(mydynamo.SPILL_DIR).String()
+	diskBudget := dynamoConfigs.Key(mydynamo.DISK_BUDGET).MustInt64(0)
+
+	//Buckets shared by every node, one [bucket.<name>] section each
+	buckets := make([]mydynamo.BucketProps, 0)
+	for _, section := range configContent.Sections() {
+		if !strings.HasPrefix(section.Name(), mydynamo.BUCKET_SECTION_PREFIX) {
+			continue
+		}
+		props := mydynamo.BucketProps{
+			Name:         strings.TrimPrefix(section.Name(), mydynamo.BUCKET_SECTION_PREFIX),
+			N:            section.Key(mydynamo.N_VALUE).MustInt(0),
+			R:            section.Key(mydynamo.R_VALUE).MustInt(0),
+			W:            section.Key(mydynamo.W_VALUE).MustInt(0),
+			ConflictMode: section.Key(mydynamo.CONFLICT_MODE).String(),
+			Causality:    section.Key(mydynamo.CAUSALITY).String(),
+			Compression:  section.Key(mydynamo.COMPRESSION).String(),
+			TTL:          section.Key(mydynamo.TTL).MustInt(0),
+			Version:      1,
+		}
+		if err := mydynamo.ValidateBucket(props, cluster_size); err != nil {
+			log.Println(err)
+			log.Println("Failed to load config file, invalid bucket section:", section.Name())
+			log.Println(mydynamo.USAGE_STRING)
+			os.Exit(mydynamo.EX_CONFIG)
+		}
+		buckets = append(buckets, props)
+	}
 	fmt.Println("Done loading configurations")
 
 	//keep a list of servers so we can communicate with them
@@ -60,6 +96,24 @@ func main() {
 
 		//Create a server instance
 		serverInstance := mydynamo.NewDynamoServer(w_value, r_value, "localhost", strconv.Itoa(serverPort+idx), strconv.Itoa(idx))
+		if jsonRPCPort > 0 {
+			serverInstance.SetJSONRPCPort(strconv.Itoa(jsonRPCPort + idx))
+		}
+		if httpPort > 0 {
+			serverInstance.SetHTTPPort(strconv.Itoa(httpPort + idx))
+		}
+		if contextKey != "" {
+			serverInstance.SetContextKey([]byte(contextKey))
+		}
+		serverInstance.SetClockPruneThreshold(clockThreshold)
+		serverInstance.SetScrubInterval(scrubInterval)
+		serverInstance.SetMemoryBudget(memoryBudget)
+		if spillDir != "" {
+			serverInstance.SetSpillDir(spillDir, diskBudget)
+		}
+		for _, props := range buckets {
+			serverInstance.AddBucket(props)
+		}
 		serverList = append(serverList, serverInstance)
 
 		//Create an anonymous function in a goroutine that starts the server
@@ -0,0 +1,99 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitMultiPutMultiGet(t *testing.T) {
+	t.Logf("Starting MultiPut/MultiGet test")
+
+	//Two in-process nodes with W = R = 2
+	nodes := []mydynamo.DynamoNode{
+		mydynamo.NewDynamoNode("localhost", "18086"),
+		mydynamo.NewDynamoNode("localhost", "18087"),
+	}
+	for idx, node := range nodes {
+		server := mydynamo.NewDynamoServer(2, 2, node.Address, node.Port, node.Port)
+		server.SendPreferenceList(append(nodes[idx:], nodes[:idx]...), &mydynamo.Empty{})
+		ServeInProcess(server)
+	}
+	clientInstance0 := MakeConnectedClient(18086)
+	clientInstance1 := MakeConnectedClient(18087)
+
+	keys := []string{"s1", "s2", "s3"}
+	values := make([]mydynamo.PutArgs, 0)
+	for _, key := range keys {
+		values = append(values, PutFreshContext(key, []byte(key)))
+	}
+	putRes := clientInstance0.MultiPut(mydynamo.MultiPutArgs{Values: values})
+	if putRes == nil || len(putRes.Results) != len(keys) {
+		t.Fatalf("TestUnitMultiPutMultiGet: MultiPut failed")
+	}
+	for _, res := range putRes.Results {
+		if !res.Result.Success || res.Result.Replicas != 2 {
+			t.Fail()
+			t.Logf("TestUnitMultiPutMultiGet: %s was not replicated: %v", res.Key, res)
+		}
+	}
+
+	//Every value reached the second node in the batch
+	localRes := clientInstance1.GetLocalBatch(keys)
+	if len(localRes) != len(keys) {
+		t.Fatalf("TestUnitMultiPutMultiGet: GetLocalBatch failed")
+	}
+	for idx, res := range localRes {
+		if res.Err != "" || len(res.Result.EntryList) != 1 || !valuesEqual(res.Result.EntryList[0].Value, []byte(keys[idx])) {
+			t.Fail()
+			t.Logf("TestUnitMultiPutMultiGet: %s missing on second node", keys[idx])
+		}
+	}
+
+	getRes := clientInstance1.MultiGet(mydynamo.MultiGetArgs{Keys: append(keys, "missing")})
+	if getRes == nil || len(getRes.Results) != len(keys)+1 {
+		t.Fatalf("TestUnitMultiPutMultiGet: MultiGet failed")
+	}
+	for idx, res := range getRes.Results[:len(keys)] {
+		if res.Err != "" || !res.Result.QuorumMet || len(res.Result.EntryList) != 1 ||
+			!valuesEqual(res.Result.EntryList[0].Value, []byte(keys[idx])) {
+			t.Fail()
+			t.Logf("TestUnitMultiPutMultiGet: Failed to get %s: %v", keys[idx], res)
+		}
+	}
+	if len(getRes.Results[len(keys)].Result.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitMultiPutMultiGet: missing key returned a value")
+	}
+}
+
+func TestUnitPutLocalBatchPerKeyErrors(t *testing.T) {
+	t.Logf("Starting PutLocalBatch per-key errors test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18128", "0"))
+	clientInstance := MakeConnectedClient(18128)
+
+	//The value with a wrong checksum is rejected, the others in the batch are still written
+	values := []mydynamo.IndexedPutArgs{
+		{Value: PutFreshContext("s1", []byte("abcde"))},
+		{Value: PutFreshContext("s2", []byte("hijkf")), Checksum: 1},
+		{Value: PutFreshContext("s3", []byte("lmnop"))},
+	}
+	res := clientInstance.PutLocalBatch(values)
+	if len(res) != len(values) {
+		t.Fatalf("TestUnitPutLocalBatchPerKeyErrors: PutLocalBatch failed")
+	}
+	if !res[0].Success || !res[2].Success || res[0].Err != "" || res[2].Err != "" {
+		t.Fail()
+		t.Logf("TestUnitPutLocalBatchPerKeyErrors: valid values were not written: %v", res)
+	}
+	if res[1].Success || res[1].Err != mydynamo.ErrChecksumMismatch.Error() {
+		t.Fail()
+		t.Logf("TestUnitPutLocalBatchPerKeyErrors: corrupt value was not rejected: %v", res[1])
+	}
+
+	got := clientInstance.GetLocalBatch([]string{"s1", "s2", "s3"})
+	if len(got) != 3 || len(got[0].Result.EntryList) != 1 || len(got[1].Result.EntryList) != 0 || len(got[2].Result.EntryList) != 1 {
+		t.Fail()
+		t.Logf("TestUnitPutLocalBatchPerKeyErrors: unexpected stored values: %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnitBucketsDoNotCollide(t *testing.T) {
+	t.Logf("Starting bucket test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18085", "0"))
+	clientInstance := MakeConnectedClient(18085)
+
+	props := clientInstance.SetBucket(mydynamo.BucketProps{Name: "users", TTL: 1})
+	if props == nil || props.Version != 1 {
+		t.Fatalf("TestUnitBucketsDoNotCollide: SetBucket failed")
+	}
+	if clientInstance.SetBucket(mydynamo.BucketProps{}) != nil {
+		t.Fail()
+		t.Logf("TestUnitBucketsDoNotCollide: default bucket must not be reconfigured")
+	}
+
+	clientInstance.Put(PutFreshContext("s1", []byte("abcde")))
+	clientInstance.PutWithOptions(mydynamo.PutOptions{
+		Bucket:  "users",
+		Key:     "s1",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:   []byte("hijkf"),
+	})
+
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Bucket: "users", Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, []byte("hijkf")) {
+		t.Fail()
+		t.Logf("TestUnitBucketsDoNotCollide: Failed to get bucket value")
+	}
+	gotValuePtr := clientInstance.Get("s1")
+	if gotValuePtr == nil || len(gotValuePtr.EntryList) != 1 || !valuesEqual(gotValuePtr.EntryList[0].Value, []byte("abcde")) {
+		t.Fail()
+		t.Logf("TestUnitBucketsDoNotCollide: Failed to get default bucket value")
+	}
+
+	//Values in "users" expire after the bucket TTL, values in the default bucket do not
+	time.Sleep(1100 * time.Millisecond)
+	got, err = clientInstance.GetV2(mydynamo.GetArgs{Bucket: "users", Key: "s1"})
+	if err != nil || len(got.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitBucketsDoNotCollide: value did not expire")
+	}
+	gotValuePtr = clientInstance.Get("s1")
+	if gotValuePtr == nil || len(gotValuePtr.EntryList) != 1 {
+		t.Fail()
+		t.Logf("TestUnitBucketsDoNotCollide: default bucket value expired")
+	}
+}
+
+func TestUnitBucketTTLFromWriteTime(t *testing.T) {
+	t.Logf("Starting TTL from write time test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18127", "0"))
+	clientInstance := MakeConnectedClient(18127)
+	if clientInstance.SetBucket(mydynamo.BucketProps{Name: "users", TTL: 2}) == nil {
+		t.Fatalf("TestUnitBucketTTLFromWriteTime: SetBucket failed")
+	}
+
+	//A replica receiving a version after its TTL passed, e.g. through gossip, does not keep it
+	old := mydynamo.IndexedPutArgs{
+		Value:    PutFreshContext("\x00users\x00s1", []byte("abcde")),
+		Metadata: mydynamo.ObjectMetadata{LastModified: time.Now().Add(-3 * time.Second)},
+	}
+	clientInstance.PutLocalIndexed(old)
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Bucket: "users", Key: "s1"})
+	if err != nil || len(got.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitBucketTTLFromWriteTime: expired version was stored")
+	}
+
+	//A version received late expires TTL after it was written, not after it was received
+	late := mydynamo.IndexedPutArgs{
+		Value:    PutFreshContext("\x00users\x00s2", []byte("hijkf")),
+		Metadata: mydynamo.ObjectMetadata{LastModified: time.Now().Add(-1500 * time.Millisecond)},
+	}
+	if !clientInstance.PutLocalIndexed(late) {
+		t.Fatalf("TestUnitBucketTTLFromWriteTime: PutLocalIndexed failed")
+	}
+	time.Sleep(700 * time.Millisecond)
+	got, err = clientInstance.GetV2(mydynamo.GetArgs{Bucket: "users", Key: "s2"})
+	if err != nil || len(got.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitBucketTTLFromWriteTime: late version did not expire on time")
+	}
+}
+
+func TestUnitBucketConcurrentChanges(t *testing.T) {
+	t.Logf("Starting concurrent bucket changes test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18131", "0"))
+	clientInstance := MakeConnectedClient(18131)
+
+	//Buckets change while writes to them are coordinated; run with -race to check
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client := MakeConnectedClient(18131)
+		defer client.CleanConn()
+		for i := 0; i < 50; i++ {
+			client.SetBucket(mydynamo.BucketProps{Name: "users", TTL: 60 + i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		client := MakeConnectedClient(18131)
+		defer client.CleanConn()
+		for i := 0; i < 50; i++ {
+			client.PutWithOptions(mydynamo.PutOptions{Bucket: "users", Key: "s1", Context: mydynamo.NewContext(mydynamo.NewVectorClock()), Value: []byte("abcde")})
+		}
+	}()
+	wg.Wait()
+
+	props := clientInstance.GetBucket("users")
+	if props == nil || props.Version != 50 || props.TTL != 109 {
+		t.Fail()
+		t.Logf("TestUnitBucketConcurrentChanges: unexpected bucket %v", props)
+	}
+}
+
+func TestUnitValidateBucket(t *testing.T) {
+	t.Logf("Starting bucket validation test")
+
+	//Typos in the config file are caught at startup, as SetBucket would catch them
+	valid := mydynamo.BucketProps{Name: "users", N: 3, R: 2, W: 2, ConflictMode: "lww", Causality: "dvv", Compression: "gzip"}
+	if err := mydynamo.ValidateBucket(valid, 3); err != nil {
+		t.Fatalf("TestUnitValidateBucket: valid bucket rejected: %v", err)
+	}
+	invalid := []mydynamo.BucketProps{
+		{Name: ""},
+		{Name: "users", ConflictMode: "lwww"},
+		{Name: "users", Compression: "zstd"},
+		{Name: "users", Causality: "dv"},
+		{Name: "users", N: 2, R: 3},
+		{Name: "users", W: 4},
+		{Name: "users", TTL: -1},
+	}
+	for _, props := range invalid {
+		if mydynamo.ValidateBucket(props, 3) != mydynamo.ErrInvalidBucket {
+			t.Fail()
+			t.Logf("TestUnitValidateBucket: invalid bucket accepted: %v", props)
+		}
+	}
+}
+
+func TestUnitBucketTTLSweep(t *testing.T) {
+	t.Logf("Starting TTL sweep test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18134", "0"))
+	clientInstance := MakeConnectedClient(18134)
+	if clientInstance.SetBucket(mydynamo.BucketProps{Name: "cache", TTL: 1}) == nil {
+		t.Fatalf("TestUnitBucketTTLSweep: SetBucket failed")
+	}
+
+	//A key written once and never read again still leaves memory once its TTL passes
+	clientInstance.PutWithOptions(mydynamo.PutOptions{Bucket: "cache", Key: "s1", Context: mydynamo.NewContext(mydynamo.NewVectorClock()), Value: []byte("abcde")})
+	stats := clientInstance.Stats()
+	if stats == nil || stats.MemoryUsed == 0 {
+		t.Fatalf("TestUnitBucketTTLSweep: write was not accounted: %v", stats)
+	}
+	time.Sleep(1000*time.Millisecond + 2*mydynamo.EXPIRY_SWEEP_INTERVAL)
+	stats = clientInstance.Stats()
+	if stats == nil || stats.MemoryUsed != 0 {
+		t.Fail()
+		t.Logf("TestUnitBucketTTLSweep: expired key was not swept: %v", stats)
+	}
+}
@@ -0,0 +1,68 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"math/rand"
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitChunkedValues(t *testing.T) {
+	t.Logf("Starting chunked values test")
+
+	clients := ServeCluster(2, 2, 18110, 18111)
+	clientA, clientB := clients[0], clients[1]
+
+	value := make([]byte, 2*mydynamo.CHUNK_SIZE+12345)
+	rand.New(rand.NewSource(1)).Read(value)
+	res, err := clientA.PutStream(mydynamo.PutOptions{
+		Key:     "big",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+	}, bytes.NewReader(value))
+	if err != nil || !res.Success {
+		t.Fatalf("TestUnitChunkedValues: PutStream failed %v %v", res, err)
+	}
+
+	//The stored version is a manifest of three chunks
+	got := clientB.Get("big")
+	if got == nil || len(got.EntryList) != 1 {
+		t.Fatalf("TestUnitChunkedValues: Get failed %v", got)
+	}
+	manifest, ok := mydynamo.DecodeManifest(got.EntryList[0].Value)
+	if !ok || len(manifest.Chunks) != 3 || manifest.Size != int64(len(value)) {
+		t.Fatalf("TestUnitChunkedValues: unexpected manifest %v", manifest)
+	}
+
+	//Chunks were replicated along with the manifest
+	var streamed bytes.Buffer
+	result, err := clientB.GetStream(mydynamo.GetArgs{Key: "big"}, &streamed)
+	if err != nil || !bytes.Equal(streamed.Bytes(), value) {
+		t.Fail()
+		t.Logf("TestUnitChunkedValues: GetStream returned %d bytes, %v", streamed.Len(), err)
+	}
+
+	//Small values are stored inline
+	_, err = clientA.PutStream(mydynamo.PutOptions{
+		Key:     "big",
+		Context: result.Context,
+	}, bytes.NewReader([]byte("small")))
+	if err != nil {
+		t.Fatalf("TestUnitChunkedValues: PutStream failed %v", err)
+	}
+	got = clientB.Get("big")
+	if got == nil || len(got.EntryList) != 1 || string(got.EntryList[0].Value) != "small" {
+		t.Fatalf("TestUnitChunkedValues: unexpected inline value %v", got)
+	}
+
+	//The chunks of the overwritten manifest are collected on every replica once unreferenced
+	for _, client := range []*mydynamo.RPCClient{clientA, clientB} {
+		if collected := client.CollectChunks(0); collected != 3 {
+			t.Fail()
+			t.Logf("TestUnitChunkedValues: expected 3 chunks collected, got %d", collected)
+		}
+	}
+	if _, err := clientA.GetChunk(manifest.Chunks[0]); err != mydynamo.ErrChunkNotFound {
+		t.Fail()
+		t.Logf("TestUnitChunkedValues: collected chunk still served, %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"mydynamo"
+	"reflect"
+	"testing"
+)
+
+func TestUnitClockEncodingStream(t *testing.T) {
+	t.Logf("Starting clock encoding stream test")
+
+	clocks := make([]mydynamo.VectorClock, 0)
+	for i := 0; i < 10; i++ {
+		clock := mydynamo.NewVectorClock()
+		clock.VectorClock["node-0"] = i
+		clock.VectorClock["node-1"] = 2 * i
+		clock.Timestamps["node-0"] = int64(1000 + i)
+		clock.Timestamp = int64(i) << 16
+		clock.Writer = "node-1"
+		clocks = append(clocks, clock)
+	}
+
+	var buf bytes.Buffer
+	encoder := mydynamo.NewVectorClockEncoder(&buf)
+	for _, clock := range clocks {
+		if err := encoder.Encode(clock); err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+	}
+
+	var gobBuf bytes.Buffer
+	gobEncoder := gob.NewEncoder(&gobBuf)
+	for _, clock := range clocks {
+		gobEncoder.Encode(clock.VectorClock)
+		gobEncoder.Encode(clock.Timestamps)
+	}
+	if buf.Len() >= gobBuf.Len() {
+		t.Fail()
+		t.Logf("stream should be smaller than gob maps: %d >= %d", buf.Len(), gobBuf.Len())
+	}
+
+	decoder := mydynamo.NewVectorClockDecoder(&buf)
+	for i, expected := range clocks {
+		var clock mydynamo.VectorClock
+		if err := decoder.Decode(&clock); err != nil {
+			t.Fatalf("decode %d failed: %v", i, err)
+		}
+		if !reflect.DeepEqual(clock, expected) {
+			t.Fail()
+			t.Logf("clock %d mismatch: %v != %v", i, clock, expected)
+		}
+	}
+	var clock mydynamo.VectorClock
+	if err := decoder.Decode(&clock); err != io.EOF {
+		t.Fail()
+		t.Logf("expected EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestUnitClockEncodingGob(t *testing.T) {
+	t.Logf("Starting clock encoding gob test")
+
+	clock := mydynamo.NewVectorClock()
+	clock.Increment("0")
+	clock.Dot = mydynamo.Dot{NodeID: "1", Counter: 4}
+	entry := mydynamo.ObjectEntry{Context: mydynamo.NewContext(clock), Value: []byte("abc")}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		t.Fatalf("gob encode failed: %v", err)
+	}
+	var decoded mydynamo.ObjectEntry
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("gob decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, entry) {
+		t.Fail()
+		t.Logf("entry mismatch: %v != %v", decoded, entry)
+	}
+}
+
+func FuzzClockEncodingRoundTrip(f *testing.F) {
+	f.Add("0", 1, int64(0), "1", 2, int64(5), int64(1<<20), "", 0)
+	f.Add("a", -3, int64(-1), "a", 7, int64(0), int64(0), "b", 9)
+	f.Add("", 0, int64(1), "x\x00y", 1<<40, int64(1<<62), int64(-1), "x\x00y", -1)
+
+	f.Fuzz(func(t *testing.T, id0 string, v0 int, ts0 int64, id1 string, v1 int, ts1 int64, timestamp int64, dotID string, dotCounter int) {
+		clock := mydynamo.NewVectorClock()
+		clock.VectorClock[id0] = v0
+		clock.VectorClock[id1] = v1
+		if ts0 != 0 {
+			clock.Timestamps[id0] = ts0
+		}
+		if ts1 != 0 {
+			clock.Timestamps[id1] = ts1
+		}
+		clock.Timestamp = timestamp
+		clock.Writer = id1
+		clock.Dot = mydynamo.Dot{NodeID: dotID, Counter: dotCounter}
+
+		data, err := clock.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		var decoded mydynamo.VectorClock
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unmarshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, clock) {
+			t.Fatalf("round trip mismatch: %v != %v", decoded, clock)
+		}
+	})
+}
+
+func FuzzClockEncodingDecode(f *testing.F) {
+	clock := mydynamo.NewVectorClock()
+	clock.Increment("0")
+	clock.Increment("1")
+	data, _ := clock.MarshalBinary()
+	f.Add(data)
+	f.Add([]byte{1, 0, 0, 0, 0, 0})
+	f.Add([]byte{1, 1, 1, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var decoded mydynamo.VectorClock
+		if decoded.UnmarshalBinary(data) != nil {
+			return
+		}
+		//Any accepted encoding must survive a round trip
+		encoded, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		var again mydynamo.VectorClock
+		if err := again.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("unmarshal of re-encoded clock failed: %v", err)
+		}
+		if !reflect.DeepEqual(again, decoded) {
+			t.Fatalf("round trip mismatch: %v != %v", again, decoded)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitCompressedBuckets(t *testing.T) {
+	t.Logf("Starting compressed buckets test")
+
+	clients := ServeCluster(2, 2, 18112, 18113)
+	clientA, clientB := clients[0], clients[1]
+
+	if clientA.SetBucket(mydynamo.BucketProps{Name: "docs", Compression: "zstd"}) != nil {
+		t.Fail()
+		t.Logf("TestUnitCompressedBuckets: unknown codec accepted")
+	}
+	if clientA.SetBucket(mydynamo.BucketProps{Name: "docs", Compression: mydynamo.CODEC_GZIP}) == nil {
+		t.Fatalf("TestUnitCompressedBuckets: SetBucket failed")
+	}
+
+	value := bytes.Repeat([]byte(`{"user": "alice", "roles": ["admin", "dev"]}`), 100)
+	res := clientA.PutWithOptions(mydynamo.PutOptions{
+		Bucket:  "docs",
+		Key:     "s1",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:   value,
+	})
+	if res == nil || !res.Success {
+		t.Fatalf("TestUnitCompressedBuckets: put failed")
+	}
+
+	//Both replicas store the compressed value, with its codec
+	for _, client := range []*mydynamo.RPCClient{clientA, clientB} {
+		stored := client.GetLocalStored("\x00docs\x00s1")
+		if stored == nil || len(stored.EntryList) != 1 || stored.EntryList[0].Codec != mydynamo.CODEC_GZIP || len(stored.EntryList[0].Value) >= len(value) {
+			t.Fatalf("TestUnitCompressedBuckets: value not stored compressed %v", stored)
+		}
+		//GetLocal hands out plain values
+		local := client.GetLocal("\x00docs\x00s1")
+		if local == nil || len(local.EntryList) != 1 || !valuesEqual(local.EntryList[0].Value, value) {
+			t.Fatalf("TestUnitCompressedBuckets: GetLocal did not return the plain value %v", local)
+		}
+	}
+	got, err := clientB.GetV2(mydynamo.GetArgs{Bucket: "docs", Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, value) {
+		t.Fatalf("TestUnitCompressedBuckets: GetV2 did not return the plain value %v", err)
+	}
+
+	//Switching codecs leaves older versions readable
+	if clientB.SetBucket(mydynamo.BucketProps{Name: "docs", Compression: mydynamo.CODEC_FLATE}) == nil {
+		t.Fatalf("TestUnitCompressedBuckets: SetBucket failed")
+	}
+	clientB.PutWithOptions(mydynamo.PutOptions{
+		Bucket:  "docs",
+		Key:     "s2",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:   value,
+	})
+	stored := clientA.GetLocalStored("\x00docs\x00s2")
+	if stored == nil || len(stored.EntryList) != 1 || stored.EntryList[0].Codec != mydynamo.CODEC_FLATE {
+		t.Fatalf("TestUnitCompressedBuckets: value not stored with the new codec %v", stored)
+	}
+	for _, key := range []string{"s1", "s2"} {
+		got, err := clientA.GetV2(mydynamo.GetArgs{Bucket: "docs", Key: key})
+		if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, value) {
+			t.Fail()
+			t.Logf("TestUnitCompressedBuckets: %s unreadable after switching codecs", key)
+		}
+	}
+
+	//Checksums cover the plain value
+	metadata, err := clientA.GetV3(mydynamo.GetArgs{Bucket: "docs", Key: "s1"})
+	if err != nil || metadata.EntryList[0].Metadata.Checksum != mydynamo.Checksum(value) {
+		t.Fail()
+		t.Logf("TestUnitCompressedBuckets: checksum not taken on the plain value")
+	}
+
+	stats := clientA.Stats()
+	if stats == nil || stats.CompressionInput != int64(len(value)) || stats.CompressionRatio <= 10 {
+		t.Fail()
+		t.Logf("TestUnitCompressedBuckets: unexpected compression stats %v", stats)
+	}
+}
@@ -0,0 +1,44 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitConditionalPut(t *testing.T) {
+	t.Logf("Starting conditional Put test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18088", "0"))
+	clientInstance := MakeConnectedClient(18088)
+	fresh := mydynamo.NewContext(mydynamo.NewVectorClock())
+
+	//Create-if-absent succeeds on a new key
+	res, err := clientInstance.ConditionalPut(mydynamo.PutOptions{Key: "s1", Context: fresh, Value: []byte("abcde")})
+	if err != nil || !res.Result.Success {
+		t.Fatalf("TestUnitConditionalPut: first write failed: %v", err)
+	}
+
+	//A second writer still holding the empty context gets the current version back
+	conflict, err := clientInstance.ConditionalPut(mydynamo.PutOptions{Key: "s1", Context: fresh, Value: []byte("hijkf")})
+	if err != mydynamo.ErrConflict || len(conflict.Current) != 1 ||
+		!valuesEqual(conflict.Current[0].Value, []byte("abcde")) {
+		t.Fatalf("TestUnitConditionalPut: expected a conflict, got %v", err)
+	}
+
+	//Retrying from the current version succeeds
+	res, err = clientInstance.ConditionalPut(mydynamo.PutOptions{
+		Key:     "s1",
+		Context: conflict.Current[0].Context,
+		Value:   []byte("hijkf"),
+	})
+	if err != nil || !res.Result.Success {
+		t.Fail()
+		t.Logf("TestUnitConditionalPut: retry failed: %v", err)
+	}
+
+	gotValuePtr := clientInstance.Get("s1")
+	if gotValuePtr == nil || len(gotValuePtr.EntryList) != 1 || !valuesEqual(gotValuePtr.EntryList[0].Value, []byte("hijkf")) {
+		t.Fail()
+		t.Logf("TestUnitConditionalPut: Failed to get value")
+	}
+}
@@ -0,0 +1,78 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitContextTokenRoundTrip(t *testing.T) {
+	t.Logf("Starting context token round trip test")
+
+	clock := mydynamo.NewVectorClock()
+	clock.VectorClock = map[string]int{
+		"0": 3,
+		"1": 1,
+		"2": -1,
+	}
+
+	token := mydynamo.EncodeContextToken(mydynamo.NewContext(clock), nil)
+	context, err := mydynamo.DecodeContextToken(token, nil)
+	if err != nil {
+		t.Fatalf("TestUnitContextTokenRoundTrip: decode failed: %v", err)
+	}
+	if !context.Clock.Equals(clock) {
+		t.Fail()
+		t.Logf("TestUnitContextTokenRoundTrip: clocks differ: %v", context.Clock.VectorClock)
+	}
+
+	//Tokens carry the clock in the encoding of MarshalBinary, after their own two header bytes
+	marshaled, _ := clock.MarshalBinary()
+	if !bytes.Equal(mydynamo.EncodeContext(mydynamo.NewContext(clock))[2:], marshaled[1:]) {
+		t.Fail()
+		t.Logf("TestUnitContextTokenRoundTrip: token does not use the clock encoding")
+	}
+
+	//Update times survive the token, so that pruning keeps dropping the least recently updated entries
+	clock.Timestamps = map[string]int64{"0": 30, "1": 10, "2": 20}
+	context, err = mydynamo.DecodeContextToken(mydynamo.EncodeContextToken(mydynamo.NewContext(clock), nil), nil)
+	if err != nil {
+		t.Fatalf("TestUnitContextTokenRoundTrip: decode failed: %v", err)
+	}
+	for nodeID, updated := range clock.Timestamps {
+		if context.Clock.Timestamps[nodeID] != updated {
+			t.Fail()
+			t.Logf("TestUnitContextTokenRoundTrip: lost the update time of %s: %v", nodeID, context.Clock.Timestamps)
+		}
+	}
+	if context.Clock.Prune(2) != 1 || context.Clock.VectorClock["1"] != 0 {
+		t.Fail()
+		t.Logf("TestUnitContextTokenRoundTrip: pruned the wrong entry: %v", context.Clock.VectorClock)
+	}
+}
+
+func TestUnitContextTokenTampered(t *testing.T) {
+	t.Logf("Starting context token tamper test")
+
+	key := []byte("secret")
+	clock := mydynamo.NewVectorClock()
+	clock.Increment("0")
+
+	token := mydynamo.EncodeContextToken(mydynamo.NewContext(clock), key)
+	if _, err := mydynamo.DecodeContextToken(token, key); err != nil {
+		t.Fail()
+		t.Logf("TestUnitContextTokenTampered: valid token rejected: %v", err)
+	}
+
+	//A token signed with another key, or not signed at all, must be rejected
+	forged := mydynamo.EncodeContextToken(mydynamo.NewContext(clock), []byte("other"))
+	if _, err := mydynamo.DecodeContextToken(forged, key); err != mydynamo.ErrContextTampered {
+		t.Fail()
+		t.Logf("TestUnitContextTokenTampered: forged token accepted")
+	}
+	unsigned := mydynamo.EncodeContextToken(mydynamo.NewContext(clock), nil)
+	if _, err := mydynamo.DecodeContextToken(unsigned, key); err != mydynamo.ErrContextTampered {
+		t.Fail()
+		t.Logf("TestUnitContextTokenTampered: unsigned token accepted")
+	}
+}
@@ -0,0 +1,102 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"sync"
+	"testing"
+)
+
+func TestUnitCRDTCounterConverges(t *testing.T) {
+	t.Logf("Starting CRDT counter test")
+
+	//Two in-process nodes with W = R = 1, so increments through each are concurrent
+	nodes := []mydynamo.DynamoNode{
+		mydynamo.NewDynamoNode("localhost", "18090"),
+		mydynamo.NewDynamoNode("localhost", "18091"),
+	}
+	for idx, node := range nodes {
+		server := mydynamo.NewDynamoServer(1, 1, node.Address, node.Port, node.Port)
+		server.SendPreferenceList(append(nodes[idx:], nodes[:idx]...), &mydynamo.Empty{})
+		ServeInProcess(server)
+	}
+	clientInstance0 := MakeConnectedClient(18090)
+	clientInstance1 := MakeConnectedClient(18091)
+
+	clientInstance0.Increment(mydynamo.CRDTArgs{Key: "c1", Delta: 5})
+	clientInstance1.Increment(mydynamo.CRDTArgs{Key: "c1", Delta: 3})
+	clientInstance1.Increment(mydynamo.CRDTArgs{Key: "c1", Delta: -1})
+
+	//Gossip delivers both states to each node, where they merge instead of becoming siblings
+	clientInstance0.Gossip()
+	clientInstance1.Gossip()
+	for _, clientInstance := range []*mydynamo.RPCClient{clientInstance0, clientInstance1} {
+		res := clientInstance.Fetch(mydynamo.CRDTArgs{Key: "c1"})
+		if res == nil || res.Type != mydynamo.CRDT_COUNTER || res.Counter != 7 {
+			t.Fail()
+			t.Logf("TestUnitCRDTCounterConverges: expected 7, got %v", res)
+		}
+		got := clientInstance.Get("c1")
+		if got == nil || len(got.EntryList) != 1 {
+			t.Fail()
+			t.Logf("TestUnitCRDTCounterConverges: counter has siblings")
+		}
+	}
+}
+
+func TestUnitCRDTSetAndMap(t *testing.T) {
+	t.Logf("Starting CRDT set and map test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18092", "0"))
+	clientInstance := MakeConnectedClient(18092)
+
+	clientInstance.AddToSet(mydynamo.CRDTArgs{Key: "set", Element: []byte("a")})
+	clientInstance.AddToSet(mydynamo.CRDTArgs{Key: "set", Element: []byte("b")})
+	res := clientInstance.RemoveFromSet(mydynamo.CRDTArgs{Key: "set", Element: []byte("a")})
+	if res == nil || len(res.Set) != 1 || !valuesEqual(res.Set[0], []byte("b")) {
+		t.Fail()
+		t.Logf("TestUnitCRDTSetAndMap: wrong set %v", res)
+	}
+
+	//Operations on the wrong type are rejected
+	if clientInstance.Increment(mydynamo.CRDTArgs{Key: "set", Delta: 1}) != nil {
+		t.Fail()
+		t.Logf("TestUnitCRDTSetAndMap: incremented a set")
+	}
+
+	clientInstance.Increment(mydynamo.CRDTArgs{Key: "profile", Field: "visits", Delta: 2})
+	clientInstance.SetRegister(mydynamo.CRDTArgs{Key: "profile", Field: "name", Element: []byte("ann")})
+	res = clientInstance.Fetch(mydynamo.CRDTArgs{Key: "profile"})
+	if res == nil || res.Type != mydynamo.CRDT_MAP || res.Map["visits"].Counter != 2 ||
+		!valuesEqual(res.Map["name"].Register, []byte("ann")) {
+		t.Fail()
+		t.Logf("TestUnitCRDTSetAndMap: wrong map %v", res)
+	}
+}
+
+func TestUnitCRDTConcurrentIncrements(t *testing.T) {
+	t.Logf("Starting concurrent increments test")
+
+	clientInstance := ServeCluster(2, 2, 18123, 18124)[0]
+
+	//10 clients increment the same counter at once through the same node, which reads it
+	//from and writes it to the other node in between
+	var wg sync.WaitGroup
+	for c := 0; c < 10; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := MakeConnectedClient(18123)
+			defer client.CleanConn()
+			for i := 0; i < 10; i++ {
+				client.Increment(mydynamo.CRDTArgs{Key: "c1", Delta: 1})
+			}
+		}()
+	}
+	wg.Wait()
+
+	res := clientInstance.Fetch(mydynamo.CRDTArgs{Key: "c1"})
+	if res == nil || res.Counter != 100 {
+		t.Fail()
+		t.Logf("TestUnitCRDTConcurrentIncrements: expected 100, got %v", res)
+	}
+}
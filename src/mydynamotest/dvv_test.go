@@ -0,0 +1,76 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"mydynamo"
+	"sync"
+	"testing"
+)
+
+func TestUnitDottedVersionVectorBucket(t *testing.T) {
+	t.Logf("Starting dotted version vector bucket test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18095", "0"))
+	clientInstance := MakeConnectedClient(18095)
+	if clientInstance.SetBucket(mydynamo.BucketProps{Name: "carts", Causality: mydynamo.CAUSALITY_DVV}) == nil {
+		t.Fatalf("TestUnitDottedVersionVectorBucket: SetBucket failed")
+	}
+
+	//Two clients write from the same empty context through the same coordinator.
+	//Neither write is lost: both are kept as siblings
+	fresh := mydynamo.NewContext(mydynamo.NewVectorClock())
+	for _, value := range []string{"abcde", "hijkf"} {
+		res := clientInstance.PutWithOptions(mydynamo.PutOptions{Bucket: "carts", Key: "s1", Context: fresh, Value: []byte(value)})
+		if res == nil || !res.Success {
+			t.Fatalf("TestUnitDottedVersionVectorBucket: Put failed")
+		}
+	}
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Bucket: "carts", Key: "s1"})
+	if err != nil || len(got.EntryList) != 2 {
+		t.Fatalf("TestUnitDottedVersionVectorBucket: expected 2 siblings, got %v %v", got, err)
+	}
+
+	//A write from the combined context replaces both siblings
+	clientInstance.PutWithOptions(mydynamo.PutOptions{Bucket: "carts", Key: "s1", Context: got.Context, Value: []byte("merged")})
+	got, err = clientInstance.GetV2(mydynamo.GetArgs{Bucket: "carts", Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, []byte("merged")) {
+		t.Fail()
+		t.Logf("TestUnitDottedVersionVectorBucket: siblings were not replaced: %v %v", got, err)
+	}
+}
+
+func TestUnitDottedVersionVectorConcurrentPuts(t *testing.T) {
+	t.Logf("Starting concurrent dotted puts test")
+
+	clientInstance := ServeCluster(2, 2, 18125, 18126)[0]
+	props := mydynamo.BucketProps{Name: "carts", Causality: mydynamo.CAUSALITY_DVV, Compression: mydynamo.CODEC_GZIP}
+	if clientInstance.SetBucket(props) == nil {
+		t.Fatalf("TestUnitDottedVersionVectorConcurrentPuts: SetBucket failed")
+	}
+
+	//10 clients write from the same empty context at once through the same node, which
+	//compresses each large value between stamping and storing it. Each write gets its
+	//own dot and is kept
+	var wg sync.WaitGroup
+	for c := 0; c < 10; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			client := MakeConnectedClient(18125)
+			defer client.CleanConn()
+			fresh := mydynamo.NewContext(mydynamo.NewVectorClock())
+			value := bytes.Repeat([]byte{byte('a' + c)}, 1<<22)
+			client.PutWithOptions(mydynamo.PutOptions{Bucket: "carts", Key: "s1", Context: fresh, Value: value})
+		}(c)
+	}
+	wg.Wait()
+
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Bucket: "carts", Key: "s1"})
+	if err != nil {
+		t.Fatalf("TestUnitDottedVersionVectorConcurrentPuts: GetV2 failed: %v", err)
+	}
+	if len(got.EntryList) != 10 {
+		t.Fail()
+		t.Logf("TestUnitDottedVersionVectorConcurrentPuts: expected 10 siblings, got %d", len(got.EntryList))
+	}
+}
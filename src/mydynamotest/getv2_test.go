@@ -0,0 +1,86 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitGetV2QuorumNotMet(t *testing.T) {
+	t.Logf("Starting GetV2 quorum test")
+
+	//R = 2, but the only other node in the preference list is never started
+	clientInstance := ServeNodes(1, 2, LocalNodes(18083, 18093), 1)[0]
+
+	clientInstance.Put(PutFreshContext("s1", []byte("abcde")))
+
+	//The failed read still reports how many replicas answered, and nothing else
+	failed, err := clientInstance.GetV2(mydynamo.GetArgs{Key: "s1"})
+	if err != mydynamo.ErrQuorumNotMet {
+		t.Fail()
+		t.Logf("TestUnitGetV2QuorumNotMet: expected ErrQuorumNotMet, got %v", err)
+	}
+	if failed == nil || failed.QuorumMet || failed.Replicas != 1 || len(failed.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitGetV2QuorumNotMet: expected the replica count only, got %v", failed)
+	}
+	failedV3, err := clientInstance.GetV3(mydynamo.GetArgs{Key: "s1"})
+	if err != mydynamo.ErrQuorumNotMet || failedV3 == nil || failedV3.Replicas != 1 || len(failedV3.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitGetV2QuorumNotMet: expected GetV3 to report 1 replica, got %v %v", failedV3, err)
+	}
+	failedScan, err := clientInstance.Scan(mydynamo.ScanArgs{})
+	if err != mydynamo.ErrQuorumNotMet || failedScan == nil || failedScan.Replicas != 1 || len(failedScan.Entries) != 0 {
+		t.Fail()
+		t.Logf("TestUnitGetV2QuorumNotMet: expected Scan to report 1 replica, got %v %v", failedScan, err)
+	}
+
+	res, err := clientInstance.GetV2(mydynamo.GetArgs{Key: "s1", AllowPartial: true})
+	if err != nil || res.QuorumMet || res.Replicas != 1 {
+		t.Fatalf("TestUnitGetV2QuorumNotMet: unexpected partial result %v %v", res, err)
+	}
+	if len(res.EntryList) != 1 || !valuesEqual(res.EntryList[0].Value, []byte("abcde")) {
+		t.Fail()
+		t.Logf("TestUnitGetV2QuorumNotMet: Failed to get partial value")
+	}
+}
+
+func TestUnitConsistencyLevels(t *testing.T) {
+	t.Logf("Starting consistency level test")
+
+	//Node defaults are R = W = 2 with the second node down
+	clientInstance := ServeNodes(2, 2, LocalNodes(18084, 18094), 1)[0]
+
+	one := mydynamo.Consistency{Level: mydynamo.CONSISTENCY_ONE}
+	res := clientInstance.PutWithOptions(mydynamo.PutOptions{
+		Key:         "s1",
+		Context:     mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:       []byte("abcde"),
+		Consistency: one,
+	})
+	if res == nil || !res.Success {
+		t.Fail()
+		t.Logf("TestUnitConsistencyLevels: Put with ONE failed")
+	}
+	if clientInstance.Put(PutFreshContext("s2", []byte("abcde"))) {
+		t.Fail()
+		t.Logf("TestUnitConsistencyLevels: Put with default W should fail")
+	}
+
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Key: "s1", Consistency: one})
+	if err != nil || !got.QuorumMet || len(got.EntryList) != 1 {
+		t.Fail()
+		t.Logf("TestUnitConsistencyLevels: Get with ONE failed: %v", err)
+	}
+
+	all := mydynamo.Consistency{Level: mydynamo.CONSISTENCY_ALL}
+	if _, err = clientInstance.GetV2(mydynamo.GetArgs{Key: "s1", Consistency: all}); err != mydynamo.ErrQuorumNotMet {
+		t.Fail()
+		t.Logf("TestUnitConsistencyLevels: Get with ALL should not meet quorum, got %v", err)
+	}
+
+	tooMany := mydynamo.Consistency{Level: mydynamo.CONSISTENCY_EXPLICIT, R: 3}
+	if _, err = clientInstance.GetV2(mydynamo.GetArgs{Key: "s1", Consistency: tooMany}); err != mydynamo.ErrInvalidConsistency {
+		t.Fail()
+		t.Logf("TestUnitConsistencyLevels: R > N should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+	"time"
+)
+
+func TestUnitHLCMonotonic(t *testing.T) {
+	t.Logf("Starting hybrid logical clock test")
+
+	clock := mydynamo.NewHybridClock()
+	last := clock.Now()
+	for i := 0; i < 1000; i++ {
+		next := clock.Now()
+		if next <= last {
+			t.Fatalf("TestUnitHLCMonotonic: timestamp went backwards")
+		}
+		last = next
+	}
+
+	//A timestamp received from a node whose clock runs ahead moves ours past it
+	remote := last + 1<<30
+	clock.Update(remote)
+	if clock.Now() <= remote {
+		t.Fail()
+		t.Logf("TestUnitHLCMonotonic: clock did not advance past remote timestamp")
+	}
+
+	//A timestamp further ahead than HLC_MAX_DRIFT is ignored
+	future := time.Now().Add(2*mydynamo.HLC_MAX_DRIFT).UnixNano() / int64(time.Millisecond) << mydynamo.HLC_LOGICAL_BITS
+	clock.Update(future)
+	if clock.Now() >= future {
+		t.Fail()
+		t.Logf("TestUnitHLCMonotonic: clock followed a timestamp beyond the maximum drift")
+	}
+}
+
+func TestUnitHLCRejectsDriftedVersions(t *testing.T) {
+	t.Logf("Starting drifted version test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18130", "0"))
+	clientInstance := MakeConnectedClient(18130)
+
+	//A version stamped by a node whose clock runs far ahead is not stored
+	value := PutFreshContext("s1", []byte("abcde"))
+	value.Context.Clock.Timestamp = time.Now().Add(2*mydynamo.HLC_MAX_DRIFT).UnixNano() / int64(time.Millisecond) << mydynamo.HLC_LOGICAL_BITS
+	clientInstance.PutLocal(value)
+	got := clientInstance.GetLocal("s1")
+	if got == nil || len(got.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitHLCRejectsDriftedVersions: drifted version was stored")
+	}
+
+	//Writes coordinated afterwards are stamped with the local time
+	res := clientInstance.PutWithOptions(mydynamo.PutOptions{Key: "s2", Context: mydynamo.NewContext(mydynamo.NewVectorClock()), Value: []byte("hijkf")})
+	if res == nil || mydynamo.HLCTime(res.Context.Clock.Timestamp).After(time.Now().Add(time.Second)) {
+		t.Fail()
+		t.Logf("TestUnitHLCRejectsDriftedVersions: unexpected write %v", res)
+	}
+}
+
+func TestUnitLastWriterWinsTieBreak(t *testing.T) {
+	t.Logf("Starting last writer wins tie break test")
+
+	//Equal timestamps are ordered by writer ID, whatever the order of the siblings
+	a := makeSibling([]byte("a"), map[string]int{"0": 1}, 5)
+	a.Context.Clock.Writer = "0"
+	b := makeSibling([]byte("b"), map[string]int{"1": 1}, 5)
+	b.Context.Clock.Writer = "1"
+
+	for _, siblings := range [][]mydynamo.ObjectEntry{{a, b}, {b, a}} {
+		merged := mydynamo.LastWriterWinsResolver{}.Resolve(siblings)
+		if len(merged) != 1 || !valuesEqual(merged[0].Value, []byte("b")) || merged[0].Context.Clock.Writer != "1" {
+			t.Fail()
+			t.Logf("TestUnitLastWriterWinsTieBreak: wrong winner %v", merged)
+		}
+	}
+}
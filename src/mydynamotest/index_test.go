@@ -0,0 +1,68 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitSecondaryIndex(t *testing.T) {
+	t.Logf("Starting secondary index test")
+
+	clients := ServeCluster(2, 1, 18106, 18107)
+	clientA, clientB := clients[0], clients[1]
+
+	put := func(client *mydynamo.RPCClient, key string, context mydynamo.Context, user string, age int64) *mydynamo.PutResult {
+		res := client.PutWithOptions(mydynamo.PutOptions{
+			Key:     key,
+			Context: context,
+			Value:   []byte(user),
+			Indexes: []mydynamo.IndexTerm{
+				{Index: "user_id", Value: user},
+				{Index: "age", Int: age, Integer: true},
+			},
+		})
+		if res == nil || !res.Success {
+			t.Fatalf("TestUnitSecondaryIndex: put of %s failed", key)
+		}
+		return res
+	}
+	empty := mydynamo.NewContext(mydynamo.NewVectorClock())
+	first := put(clientA, "sess1", empty, "alice", 30)
+	put(clientB, "sess2", empty, "bob", 41)
+	put(clientB, "sess3", empty, "alice", -5)
+
+	query := func(args mydynamo.IndexQueryArgs, expected []string) {
+		res, err := clientB.IndexQuery(args)
+		if err != nil || !keysEqual(res.Keys, expected) {
+			t.Fail()
+			t.Logf("TestUnitSecondaryIndex: query %v returned %v %v, expected %v", args, res, err, expected)
+		}
+	}
+	query(mydynamo.IndexQueryArgs{Index: "user_id", Value: "alice"}, []string{"sess1", "sess3"})
+	query(mydynamo.IndexQueryArgs{Index: "age", Integer: true, Min: -10, Max: 35}, []string{"sess1", "sess3"})
+	query(mydynamo.IndexQueryArgs{Index: "age", Integer: true, Min: 35, Max: 50}, []string{"sess2"})
+	query(mydynamo.IndexQueryArgs{Index: "user_id", Value: "carol"}, []string{})
+
+	//A newer version replaces the terms of the one it supersedes on every replica
+	put(clientA, "sess1", first.Context, "carol", 30)
+	query(mydynamo.IndexQueryArgs{Index: "user_id", Value: "alice"}, []string{"sess3"})
+	query(mydynamo.IndexQueryArgs{Index: "user_id", Value: "carol"}, []string{"sess1"})
+	if keys := clientB.IndexQueryLocal(mydynamo.IndexQueryArgs{Index: "user_id", Value: "carol"}); !keysEqual(keys, []string{"sess1"}) {
+		t.Fail()
+		t.Logf("TestUnitSecondaryIndex: replica index not updated, got %v", keys)
+	}
+
+	res := clientA.PutWithOptions(mydynamo.PutOptions{
+		Key:     "sess4",
+		Context: empty,
+		Indexes: []mydynamo.IndexTerm{{Index: "user\x00id", Value: "dave"}},
+	})
+	if res != nil {
+		t.Fail()
+		t.Logf("TestUnitSecondaryIndex: put with an invalid term should fail")
+	}
+	if _, err := clientA.IndexQuery(mydynamo.IndexQueryArgs{Index: ""}); err != mydynamo.ErrInvalidIndexTerm {
+		t.Fail()
+		t.Logf("TestUnitSecondaryIndex: expected ErrInvalidIndexTerm, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+)
+
+func TestUnitJSONRPCPutGet(t *testing.T) {
+	t.Logf("Starting JSON-RPC Put/Get test")
+
+	//A single node cluster serving JSON-RPC next to the gob endpoint
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18080", "0")
+	server.SetJSONRPCPort("18180")
+	go mydynamo.ServeDynamoServer(server)
+	time.Sleep(500 * time.Millisecond)
+
+	client, err := jsonrpc.Dial("tcp", "localhost:18180")
+	if err != nil {
+		t.Fatalf("TestUnitJSONRPCPutGet: failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var ok bool
+	err = client.Call("MyDynamo.Put", PutFreshContext("s1", []byte("abcde")), &ok)
+	if err != nil || !ok {
+		t.Fail()
+		t.Logf("TestUnitJSONRPCPutGet: Put failed: %v", err)
+	}
+
+	var result mydynamo.DynamoResult
+	err = client.Call("MyDynamo.Get", "s1", &result)
+	if err != nil {
+		t.Fatalf("TestUnitJSONRPCPutGet: Get failed: %v", err)
+	}
+	if len(result.EntryList) != 1 || !valuesEqual(result.EntryList[0].Value, []byte("abcde")) {
+		t.Fail()
+		t.Logf("TestUnitJSONRPCPutGet: Failed to get value")
+	}
+	if result.EntryList[0].Context.Clock.VectorClock["0"] != 1 {
+		t.Fail()
+		t.Logf("TestUnitJSONRPCPutGet: clock was not returned")
+	}
+}
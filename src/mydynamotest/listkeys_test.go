@@ -0,0 +1,79 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitListKeys(t *testing.T) {
+	t.Logf("Starting list keys test")
+
+	//The third node is never started
+	preferenceList := LocalNodes(18103, 18104, 18105)
+	clients := ServeNodes(1, 1, preferenceList, 2)
+	clientA, clientB := clients[0], clients[1]
+
+	one := mydynamo.Consistency{Level: mydynamo.CONSISTENCY_ONE}
+	put := func(client *mydynamo.RPCClient, bucket string, key string) {
+		res := client.PutWithOptions(mydynamo.PutOptions{
+			Bucket:      bucket,
+			Key:         key,
+			Context:     mydynamo.NewContext(mydynamo.NewVectorClock()),
+			Value:       []byte("abcde"),
+			Consistency: one,
+		})
+		if res == nil || !res.Success {
+			t.Fatalf("TestUnitListKeys: put of %s/%s failed", bucket, key)
+		}
+	}
+	put(clientA, "", "k1")
+	put(clientB, "", "k1")
+	put(clientB, "", "k2")
+	put(clientA, "", "")
+	put(clientA, "photos", "p1")
+	put(clientB, "photos", "p2")
+	put(clientA, "docs", "d1")
+
+	expected := []mydynamo.BucketKey{
+		{Bucket: "docs", Key: "d1"},
+		{Bucket: "photos", Key: "p1"},
+		{Bucket: "photos", Key: "p2"},
+		{Bucket: "", Key: ""},
+		{Bucket: "", Key: "k1"},
+		{Bucket: "", Key: "k2"},
+	}
+
+	listed := make([]mydynamo.BucketKey, 0)
+	it := clientB.IterateKeys(mydynamo.ListKeysArgs{AllBuckets: true, Limit: 2})
+	for it.Next() {
+		listed = append(listed, it.Key())
+	}
+	if it.Err() != nil || len(listed) != len(expected) {
+		t.Fatalf("TestUnitListKeys: unexpected listing %v %v", listed, it.Err())
+	}
+	for idx := range expected {
+		if listed[idx] != expected[idx] {
+			t.Fail()
+			t.Logf("TestUnitListKeys: key %d is %v, expected %v", idx, listed[idx], expected[idx])
+		}
+	}
+
+	//Resume after the third key
+	it = clientA.IterateKeys(mydynamo.ListKeysArgs{AllBuckets: true, Limit: 2})
+	for i := 0; i < 3 && it.Next(); i++ {
+	}
+	resumed := clientA.IterateKeys(mydynamo.ListKeysArgs{AllBuckets: true, Cursor: it.Cursor()})
+	if !resumed.Next() || resumed.Key() != expected[3] {
+		t.Fail()
+		t.Logf("TestUnitListKeys: resumed listing should start at %v", expected[3])
+	}
+
+	page, err := clientA.ListKeys(mydynamo.ListKeysArgs{Bucket: "photos"})
+	if err != nil || len(page.Keys) != 2 || page.Cursor != "" {
+		t.Fatalf("TestUnitListKeys: unexpected bucket listing %v %v", page, err)
+	}
+	if page.Nodes != 2 || len(page.Unreachable) != 1 || page.Unreachable[0] != preferenceList[2] {
+		t.Fail()
+		t.Logf("TestUnitListKeys: expected the third node to be unreachable, got %v", page)
+	}
+}
@@ -0,0 +1,63 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+	"time"
+)
+
+func TestUnitObjectMetadata(t *testing.T) {
+	t.Logf("Starting object metadata test")
+
+	clients := ServeCluster(2, 2, 18108, 18109)
+	clientA, clientB := clients[0], clients[1]
+
+	before := time.Now()
+	res := clientA.PutWithOptions(mydynamo.PutOptions{
+		Key:          "s1",
+		Context:      mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:        []byte(`{"user": "alice"}`),
+		ContentType:  "application/json",
+		UserMetadata: map[string]string{"owner": "alice"},
+	})
+	if res == nil || !res.Success {
+		t.Fatalf("TestUnitObjectMetadata: put failed")
+	}
+
+	//Read through the other replica
+	got, err := clientB.GetV3(mydynamo.GetArgs{Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 {
+		t.Fatalf("TestUnitObjectMetadata: GetV3 failed %v %v", got, err)
+	}
+	metadata := got.EntryList[0].Metadata
+	if metadata.ContentType != "application/json" || metadata.UserMetadata["owner"] != "alice" {
+		t.Fail()
+		t.Logf("TestUnitObjectMetadata: unexpected client metadata %v", metadata)
+	}
+	if metadata.Checksum != mydynamo.Checksum([]byte(`{"user": "alice"}`)) {
+		t.Fail()
+		t.Logf("TestUnitObjectMetadata: unexpected checksum %d", metadata.Checksum)
+	}
+	if metadata.LastModified.Before(before.Add(-time.Second)) || metadata.LastModified.After(time.Now().Add(time.Second)) {
+		t.Fail()
+		t.Logf("TestUnitObjectMetadata: unexpected last-modified time %v", metadata.LastModified)
+	}
+
+	//Versions written without metadata still get a checksum and last-modified time
+	clientB.PutLocal(PutContextWithClock("s2", []byte("abcde"), map[string]int{"18109": 1}))
+	got, err = clientA.GetV3(mydynamo.GetArgs{Key: "s2"})
+	if err != nil || len(got.EntryList) != 1 {
+		t.Fatalf("TestUnitObjectMetadata: GetV3 failed %v %v", got, err)
+	}
+	metadata = got.EntryList[0].Metadata
+	if metadata.ContentType != "" || metadata.Checksum != mydynamo.Checksum([]byte("abcde")) || metadata.LastModified.IsZero() {
+		t.Fail()
+		t.Logf("TestUnitObjectMetadata: unexpected metadata of a plain write %v", metadata)
+	}
+
+	//Known value from the CRC32C specification
+	if mydynamo.Checksum([]byte("123456789")) != 0xe3069283 {
+		t.Fail()
+		t.Logf("TestUnitObjectMetadata: Checksum is not CRC32C")
+	}
+}
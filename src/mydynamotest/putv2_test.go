@@ -0,0 +1,36 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+func TestUnitPutV2ReturnsContext(t *testing.T) {
+	t.Logf("Starting PutV2 test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18082", "0"))
+	clientInstance := MakeConnectedClient(18082)
+
+	res := clientInstance.PutV2(PutFreshContext("s1", []byte("abcde")))
+	if res == nil || !res.Success || res.Replicas != 1 || res.Siblings {
+		t.Fatalf("TestUnitPutV2ReturnsContext: unexpected result %v", res)
+	}
+	if res.Context.Clock.VectorClock["0"] != 1 {
+		t.Fail()
+		t.Logf("TestUnitPutV2ReturnsContext: clock was not incremented")
+	}
+
+	//Writing from the returned context replaces the version without a Get
+	res = clientInstance.PutV2(mydynamo.NewPutArgs("s1", res.Context, []byte("hijkf")))
+	if res == nil || !res.Success || res.Siblings || res.Context.Clock.VectorClock["0"] != 2 {
+		t.Fail()
+		t.Logf("TestUnitPutV2ReturnsContext: unexpected result %v", res)
+	}
+
+	gotValuePtr := clientInstance.Get("s1")
+	if gotValuePtr == nil || len(gotValuePtr.EntryList) != 1 ||
+		!valuesEqual(gotValuePtr.EntryList[0].Value, []byte("hijkf")) {
+		t.Fail()
+		t.Logf("TestUnitPutV2ReturnsContext: Failed to get value")
+	}
+}
@@ -0,0 +1,83 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+//Builds a sibling with the given clock entries and write timestamp
+func makeSibling(value []byte, clock map[string]int, timestamp int64) mydynamo.ObjectEntry {
+	vectorClock := mydynamo.NewVectorClock()
+	vectorClock.VectorClock = clock
+	vectorClock.Timestamp = timestamp
+	return mydynamo.ObjectEntry{Context: mydynamo.NewContext(vectorClock), Value: value}
+}
+
+func TestUnitResolverLastWriterWins(t *testing.T) {
+	t.Logf("Starting last writer wins resolver test")
+
+	siblings := []mydynamo.ObjectEntry{
+		makeSibling([]byte("late"), map[string]int{"0": 1}, 20),
+		makeSibling([]byte("early"), map[string]int{"1": 1}, 10),
+	}
+	merged := mydynamo.LastWriterWinsResolver{}.Resolve(siblings)
+	if len(merged) != 1 || !valuesEqual(merged[0].Value, []byte("late")) {
+		t.Fatalf("TestUnitResolverLastWriterWins: wrong winner %v", merged)
+	}
+	for _, sibling := range siblings {
+		if !sibling.Context.Clock.LessThan(merged[0].Context.Clock) {
+			t.Fail()
+			t.Logf("TestUnitResolverLastWriterWins: merged clock does not descend from %v", sibling.Context.Clock)
+		}
+	}
+}
+
+func TestUnitResolverUnionAndCustom(t *testing.T) {
+	t.Logf("Starting union and custom resolver test")
+
+	siblings := []mydynamo.ObjectEntry{
+		makeSibling([]byte{0x01, 0x10}, map[string]int{"0": 1}, 0),
+		makeSibling([]byte{0x02}, map[string]int{"1": 1}, 0),
+	}
+	merged := mydynamo.UnionResolver{}.Resolve(siblings)
+	if len(merged) != 1 || !valuesEqual(merged[0].Value, []byte{0x03, 0x10}) {
+		t.Fail()
+		t.Logf("TestUnitResolverUnionAndCustom: wrong union %v", merged)
+	}
+
+	firstValue := mydynamo.ResolverFunc(func(siblings []mydynamo.ObjectEntry) []byte {
+		return siblings[0].Value
+	})
+	merged = firstValue.Resolve(siblings)
+	if len(merged) != 1 || merged[0].Context.Clock.VectorClock["1"] != 1 {
+		t.Fail()
+		t.Logf("TestUnitResolverUnionAndCustom: custom resolver did not combine clocks")
+	}
+}
+
+func TestUnitResolverBucket(t *testing.T) {
+	t.Logf("Starting bucket resolver test")
+
+	ServeInProcess(mydynamo.NewDynamoServer(1, 1, "localhost", "18089", "0"))
+	clientInstance := MakeConnectedClient(18089)
+	if clientInstance.SetBucket(mydynamo.BucketProps{Name: "flags", ConflictMode: mydynamo.CONFLICT_UNION}) == nil {
+		t.Fatalf("TestUnitResolverBucket: SetBucket failed")
+	}
+	if clientInstance.SetBucket(mydynamo.BucketProps{Name: "bad", ConflictMode: "unknown"}) != nil {
+		t.Fail()
+		t.Logf("TestUnitResolverBucket: unknown conflict mode accepted")
+	}
+
+	//Two concurrent writes are merged on the node instead of becoming siblings
+	for _, put := range []mydynamo.PutArgs{
+		PutContextWithClock("s1", []byte{0x01}, map[string]int{"x": 1}),
+		PutContextWithClock("s1", []byte{0x04}, map[string]int{"y": 1}),
+	} {
+		clientInstance.PutWithOptions(mydynamo.PutOptions{Bucket: "flags", Key: put.Key, Context: put.Context, Value: put.Value})
+	}
+	got, err := clientInstance.GetV2(mydynamo.GetArgs{Bucket: "flags", Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, []byte{0x05}) {
+		t.Fail()
+		t.Logf("TestUnitResolverBucket: siblings were not merged: %v %v", got, err)
+	}
+}
@@ -0,0 +1,152 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"mydynamo"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUnitRESTPutGet(t *testing.T) {
+	t.Logf("Starting REST Put/Get test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18081", "0")
+	server.SetHTTPPort("18181")
+	go mydynamo.ServeDynamoServer(server)
+	time.Sleep(500 * time.Millisecond)
+	url := "http://localhost:18181/kv/s1"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("TestUnitRESTPutGet: GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fail()
+		t.Logf("TestUnitRESTPutGet: expected 404 for missing key, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte("abcde")))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("TestUnitRESTPutGet: PUT failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("TestUnitRESTPutGet: GET failed: %v", err)
+	}
+	var result mydynamo.RESTResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(result.Siblings) != 1 ||
+		!valuesEqual(result.Siblings[0].Value, []byte("abcde")) {
+		t.Fatalf("TestUnitRESTPutGet: Failed to get value")
+	}
+
+	//Overwrite using the context we just read
+	req, _ = http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte("hijkf")))
+	req.Header.Set(mydynamo.CONTEXT_HEADER, result.Context)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("TestUnitRESTPutGet: PUT with context failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("TestUnitRESTPutGet: GET failed: %v", err)
+	}
+	result = mydynamo.RESTResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if len(result.Siblings) != 1 || !valuesEqual(result.Siblings[0].Value, []byte("hijkf")) {
+		t.Fail()
+		t.Logf("TestUnitRESTPutGet: context was not honoured, got %v", result.Siblings)
+	}
+}
+
+func TestUnitRESTEmptyValueAndDelete(t *testing.T) {
+	t.Logf("Starting REST empty value and delete test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18129", "0")
+	server.SetHTTPPort("18182")
+	go mydynamo.ServeDynamoServer(server)
+	time.Sleep(500 * time.Millisecond)
+	url := "http://localhost:18182/kv/s1"
+
+	//An empty value is a value like any other, not a deletion
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte{}))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("TestUnitRESTEmptyValueAndDelete: PUT failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("TestUnitRESTEmptyValueAndDelete: GET failed: %v", err)
+	}
+	var result mydynamo.RESTResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(result.Siblings) != 1 || result.Siblings[0].Deleted {
+		t.Fatalf("TestUnitRESTEmptyValueAndDelete: empty value reported as deleted: %d %v", resp.StatusCode, result)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set(mydynamo.CONTEXT_HEADER, result.Context)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("TestUnitRESTEmptyValueAndDelete: DELETE failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("TestUnitRESTEmptyValueAndDelete: GET failed: %v", err)
+	}
+	result = mydynamo.RESTResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || len(result.Siblings) != 1 || !result.Siblings[0].Deleted {
+		t.Fail()
+		t.Logf("TestUnitRESTEmptyValueAndDelete: expected a tombstone, got %d %v", resp.StatusCode, result)
+	}
+}
+
+func TestUnitRESTErrorStatus(t *testing.T) {
+	t.Logf("Starting REST error status test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18133", "0")
+	server.SetHTTPPort("18183")
+	server.SetMemoryBudget(10)
+	go mydynamo.ServeDynamoServer(server)
+	time.Sleep(500 * time.Millisecond)
+
+	//Invalid keys are the client's fault
+	resp, err := http.Get("http://localhost:18183/kv/%00x")
+	if err != nil {
+		t.Fatalf("TestUnitRESTErrorStatus: GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fail()
+		t.Logf("TestUnitRESTErrorStatus: expected 400 for an invalid key, got %d", resp.StatusCode)
+	}
+
+	//A write past the memory budget, with nowhere to spill, does not fit
+	req, _ := http.NewRequest(http.MethodPut, "http://localhost:18183/kv/s1", bytes.NewReader([]byte("abcdefghijklmnop")))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("TestUnitRESTErrorStatus: PUT failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInsufficientStorage {
+		t.Fail()
+		t.Logf("TestUnitRESTErrorStatus: expected 507 for a full node, got %d", resp.StatusCode)
+	}
+}
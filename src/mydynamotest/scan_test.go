@@ -0,0 +1,92 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+)
+
+//Returns the keys of a scan page
+func scanKeys(result *mydynamo.ScanResult) []string {
+	keys := make([]string, 0)
+	for _, entry := range result.Entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
+
+//Returns true if both lists hold the same keys in the same order
+func keysEqual(keys []string, expected []string) bool {
+	if len(keys) != len(expected) {
+		return false
+	}
+	for idx := range keys {
+		if keys[idx] != expected[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUnitScanMergesReplicas(t *testing.T) {
+	t.Logf("Starting scan test")
+
+	clients := ServeCluster(1, 2, 18101, 18102)
+	clientA, clientB := clients[0], clients[1]
+
+	//Keys are split between the replicas, b2 has concurrent versions on each
+	clientA.PutLocal(PutContextWithClock("a1", []byte("1"), map[string]int{"18101": 1}))
+	clientA.PutLocal(PutContextWithClock("b1", []byte("2"), map[string]int{"18101": 1}))
+	clientB.PutLocal(PutContextWithClock("b2", []byte("3"), map[string]int{"18102": 1}))
+	clientA.PutLocal(PutContextWithClock("b2", []byte("4"), map[string]int{"18101": 1}))
+	clientB.PutLocal(PutContextWithClock("b3", []byte("5"), map[string]int{"18102": 1}))
+	clientB.PutLocal(PutContextWithClock("c1", []byte("6"), map[string]int{"18102": 1}))
+
+	all, err := clientA.Scan(mydynamo.ScanArgs{})
+	if err != nil || !keysEqual(scanKeys(all), []string{"a1", "b1", "b2", "b3", "c1"}) || all.Cursor != "" {
+		t.Fatalf("TestUnitScanMergesReplicas: unexpected scan %v %v", all, err)
+	}
+	if len(all.Entries[2].EntryList) != 2 {
+		t.Fail()
+		t.Logf("TestUnitScanMergesReplicas: b2 should have two siblings, got %v", all.Entries[2].EntryList)
+	}
+
+	//Pages of two keys
+	keys := make([]string, 0)
+	args := mydynamo.ScanArgs{StartKey: "a2", EndKey: "c1", Limit: 2}
+	pages := 0
+	for {
+		page, err := clientB.Scan(args)
+		if err != nil {
+			t.Fatalf("TestUnitScanMergesReplicas: scan failed: %v", err)
+		}
+		keys = append(keys, scanKeys(page)...)
+		pages++
+		if page.Cursor == "" {
+			break
+		}
+		args.Cursor = page.Cursor
+	}
+	if !keysEqual(keys, []string{"b1", "b2", "b3"}) || pages != 2 {
+		t.Fail()
+		t.Logf("TestUnitScanMergesReplicas: unexpected paged keys %v in %d pages", keys, pages)
+	}
+
+	prefix, err := clientA.ScanPrefix(mydynamo.ScanArgs{Prefix: "b"})
+	if err != nil || !keysEqual(scanKeys(prefix), []string{"b1", "b2", "b3"}) {
+		t.Fail()
+		t.Logf("TestUnitScanMergesReplicas: unexpected prefix scan %v %v", prefix, err)
+	}
+
+	//A scan of one replica only sees its own keys
+	one := mydynamo.Consistency{Level: mydynamo.CONSISTENCY_ONE}
+	local, err := clientA.Scan(mydynamo.ScanArgs{Consistency: one})
+	if err != nil || !keysEqual(scanKeys(local), []string{"a1", "b1", "b2"}) {
+		t.Fail()
+		t.Logf("TestUnitScanMergesReplicas: unexpected single replica scan %v %v", local, err)
+	}
+
+	if _, err = clientA.Scan(mydynamo.ScanArgs{Cursor: "!"}); err != mydynamo.ErrInvalidCursor {
+		t.Fail()
+		t.Logf("TestUnitScanMergesReplicas: expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"mydynamo"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUnitScrubRepairsCorruption(t *testing.T) {
+	t.Logf("Starting scrub test")
+
+	clients := ServeCluster(2, 2, 18114, 18115)
+	clientA, clientB := clients[0], clients[1]
+
+	res := clientA.PutWithOptions(mydynamo.PutOptions{
+		Key:         "s1",
+		Context:     mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:       []byte("abcde"),
+		ContentType: "text/plain",
+	})
+	if res == nil || !res.Success {
+		t.Fatalf("TestUnitScrubRepairsCorruption: put failed")
+	}
+	stored := clientA.GetLocalStored("s1")
+	if stored == nil || len(stored.EntryList) != 1 || stored.EntryList[0].Checksum != mydynamo.Checksum([]byte("abcde")) {
+		t.Fatalf("TestUnitScrubRepairsCorruption: value stored without its checksum %v", stored)
+	}
+
+	//A corrupt version is never served, reads fall back on the healthy replica
+	if !clientA.Corrupt("s1") {
+		t.Fatalf("TestUnitScrubRepairsCorruption: Corrupt failed")
+	}
+	local := clientA.GetLocal("s1")
+	if local == nil || len(local.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: corrupt version served %v", local)
+	}
+	got, err := clientA.GetV2(mydynamo.GetArgs{Key: "s1", AllowPartial: true})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, []byte("abcde")) {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: healthy replica not read %v", err)
+	}
+
+	//The scrubber restores the version, with its metadata, from the other replica
+	scrub := clientA.Scrub()
+	if scrub == nil || scrub.Repaired != 1 {
+		t.Fatalf("TestUnitScrubRepairsCorruption: unexpected scrub result %v", scrub)
+	}
+	entries := clientA.GetLocalV2("s1")
+	if len(entries) != 1 || !valuesEqual(entries[0].Value, []byte("abcde")) || entries[0].Metadata.ContentType != "text/plain" {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: version not repaired %v", entries)
+	}
+
+	//Corruption found by the scrubber itself is repaired in the same pass
+	clientB.Corrupt("s1")
+	scrub = clientB.Scrub()
+	if scrub == nil || scrub.Corrupt != 1 || scrub.Repaired != 1 {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: unexpected scrub result %v", scrub)
+	}
+
+	//Values that do not match their checksum are rejected on receipt
+	corrupt := mydynamo.IndexedPutArgs{
+		Value:    PutFreshContext("s2", []byte("abcde")),
+		Checksum: mydynamo.Checksum([]byte("abcdf")),
+	}
+	if clientB.PutLocalIndexed(corrupt) {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: corrupt value accepted")
+	}
+
+	for _, client := range []*mydynamo.RPCClient{clientA, clientB} {
+		stats := client.Stats()
+		if stats == nil || stats.CorruptionRepaired != 1 {
+			t.Fail()
+			t.Logf("TestUnitScrubRepairsCorruption: unexpected stats %v", stats)
+		}
+	}
+	if stats := clientB.Stats(); stats == nil || stats.CorruptionDetected != 2 {
+		t.Fail()
+		t.Logf("TestUnitScrubRepairsCorruption: unexpected stats %v", stats)
+	}
+}
+
+func TestUnitScrubSpilledKeys(t *testing.T) {
+	t.Logf("Starting spilled keys scrub test")
+
+	//Node A spills most keys to disk, node B keeps them all in memory
+	nodes := LocalNodes(18119, 18120)
+	dir := t.TempDir()
+	server := mydynamo.NewDynamoServer(2, 2, "localhost", "18119", "18119")
+	server.SetMemoryBudget(3000)
+	server.SetSpillDir(dir, 0)
+	server.SendPreferenceList(nodes, &mydynamo.Empty{})
+	ServeInProcess(server)
+	ServeNodes(2, 2, []mydynamo.DynamoNode{nodes[1], nodes[0]}, 1)
+	clientA := MakeConnectedClient(18119)
+
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte('a' + i)}, 1000)
+	}
+	for i := 0; i < 6; i++ {
+		if !clientA.Put(PutFreshContext("s"+strconv.Itoa(i), value(i))) {
+			t.Fatalf("TestUnitScrubSpilledKeys: put %d failed", i)
+		}
+	}
+
+	//Bit rot in a spill file: the last byte of a file is the last byte of a value
+	files, err := os.ReadDir(filepath.Join(dir, "18119"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("TestUnitScrubSpilledKeys: no spill files %v", err)
+	}
+	path := filepath.Join(dir, "18119", files[0].Name())
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("TestUnitScrubSpilledKeys: %v", err)
+	}
+	data[len(data)-1] ^= 1
+	if os.WriteFile(path, data, 0600) != nil {
+		t.Fatalf("TestUnitScrubSpilledKeys: failed to corrupt spill file")
+	}
+
+	scrub := clientA.Scrub()
+	if scrub == nil || scrub.Keys != 6 || scrub.Corrupt != 1 || scrub.Repaired != 1 {
+		t.Fatalf("TestUnitScrubSpilledKeys: unexpected scrub result %v", scrub)
+	}
+	for i := 0; i < 6; i++ {
+		local := clientA.GetLocal("s" + strconv.Itoa(i))
+		if local == nil || len(local.EntryList) != 1 || !valuesEqual(local.EntryList[0].Value, value(i)) {
+			t.Fail()
+			t.Logf("TestUnitScrubSpilledKeys: s%d not repaired %v", i, local)
+		}
+	}
+}
+
+func TestUnitScrubZeroChecksum(t *testing.T) {
+	t.Logf("Starting zero checksum test")
+
+	//A value whose CRC32C is 0 still gets a checksum, and corrupting it is detected
+	value := []byte{0xab, 0x9b, 0xe0, 0x9b}
+	if mydynamo.Checksum(value) != mydynamo.CHECKSUM_ZERO_CRC {
+		t.Fatalf("TestUnitScrubZeroChecksum: unexpected checksum %v", mydynamo.Checksum(value))
+	}
+	clients := ServeCluster(2, 2, 18135, 18136)
+	clientA := clients[0]
+	res := clientA.PutWithOptions(mydynamo.PutOptions{
+		Key:     "z1",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:   value,
+	})
+	if res == nil || !res.Success {
+		t.Fatalf("TestUnitScrubZeroChecksum: put failed")
+	}
+	if !clientA.Corrupt("z1") {
+		t.Fatalf("TestUnitScrubZeroChecksum: Corrupt failed")
+	}
+	local := clientA.GetLocal("z1")
+	if local == nil || len(local.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitScrubZeroChecksum: corrupt version served %v", local)
+	}
+	scrub := clientA.Scrub()
+	if scrub == nil || scrub.Repaired != 1 {
+		t.Fail()
+		t.Logf("TestUnitScrubZeroChecksum: unexpected scrub result %v", scrub)
+	}
+}
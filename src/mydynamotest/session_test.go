@@ -0,0 +1,126 @@
+package mydynamotest
+
+import (
+	"mydynamo"
+	"testing"
+	"time"
+)
+
+func TestUnitSessionReadYourWrites(t *testing.T) {
+	t.Logf("Starting session read-your-writes test")
+
+	//R = W = 1, so a write through one node is not visible through the other until gossip
+	clients := ServeCluster(1, 1, 18096, 18097)
+	clientA, clientB := clients[0], clients[1]
+
+	session := mydynamo.NewSession(clientA)
+	res := session.Put(mydynamo.PutOptions{
+		Key:     "s1",
+		Context: session.Context("", "s1"),
+		Value:   []byte("abcde"),
+	})
+	if res == nil || !res.Success {
+		t.Fatalf("TestUnitSessionReadYourWrites: Put failed")
+	}
+
+	//Plain reads through the other node miss the write
+	plain, err := clientB.GetV2(mydynamo.GetArgs{Key: "s1"})
+	if err != nil || len(plain.EntryList) != 0 {
+		t.Fatalf("TestUnitSessionReadYourWrites: expected a stale plain read, got %v %v", plain, err)
+	}
+
+	session.SetClient(clientB)
+	got, err := session.Get(mydynamo.GetArgs{Key: "s1"})
+	if err != nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, []byte("abcde")) {
+		t.Fail()
+		t.Logf("TestUnitSessionReadYourWrites: session read should see its write, got %v %v", got, err)
+	}
+
+	//With the writer down no replica has the write, the read is rejected
+	go clientA.Crash(3)
+	time.Sleep(500 * time.Millisecond)
+	_, err = session.Get(mydynamo.GetArgs{Key: "s1"})
+	if err != mydynamo.ErrStaleRead {
+		t.Fail()
+		t.Logf("TestUnitSessionReadYourWrites: expected ErrStaleRead, got %v", err)
+	}
+
+	//A new session has seen nothing and accepts the read
+	fresh := mydynamo.NewSession(clientB)
+	if _, err = fresh.Get(mydynamo.GetArgs{Key: "s1"}); err != nil {
+		t.Fail()
+		t.Logf("TestUnitSessionReadYourWrites: fresh session read failed: %v", err)
+	}
+}
+
+func TestUnitCoordinatorFromLastRead(t *testing.T) {
+	t.Logf("Starting coordinator selection test")
+
+	preferenceList := LocalNodes(18098, 18099)
+	clients := ServeNodes(1, 2, preferenceList, 2)
+	clientA, clientB := clients[0], clients[1]
+
+	//Only node A holds the key, so it serves the freshest versions of reads through B
+	clientA.PutLocal(PutFreshContext("s1", []byte("abcde")))
+	got, err := clientB.GetV2(mydynamo.GetArgs{Key: "s1"})
+	if err != nil || got.Coordinator != preferenceList[0] {
+		t.Fatalf("TestUnitCoordinatorFromLastRead: expected node A as coordinator, got %v %v", got, err)
+	}
+
+	res := clientB.PutWithOptions(mydynamo.PutOptions{
+		Key:         "s1",
+		Context:     got.Context,
+		Value:       []byte("fghij"),
+		Coordinator: got.Coordinator,
+	})
+	if res == nil || res.Coordinator != preferenceList[0] {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: write should be coordinated by node A, got %v", res)
+	}
+	if len(res.Context.Clock.VectorClock) != 1 || res.Context.Clock.VectorClock["18098"] != 1 {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: unexpected clock %v", res.Context.Clock.VectorClock)
+	}
+
+	//An unreachable coordinator falls back to the receiving node
+	res = clientB.PutWithOptions(mydynamo.PutOptions{
+		Key:         "s2",
+		Context:     mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:       []byte("abcde"),
+		Coordinator: mydynamo.NewDynamoNode("localhost", "18100"),
+	})
+	if res == nil || !res.Success || res.Coordinator != preferenceList[1] {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: expected fallback to node B, got %v", res)
+	}
+
+	//A coordinator that is reached but fails the write hands its error back instead
+	go clientA.Crash(1)
+	time.Sleep(100 * time.Millisecond)
+	res = clientB.PutWithOptions(mydynamo.PutOptions{
+		Key:         "s3",
+		Context:     mydynamo.NewContext(mydynamo.NewVectorClock()),
+		Value:       []byte("abcde"),
+		Coordinator: preferenceList[0],
+	})
+	if res != nil {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: expected the coordinator's error, got %v", res)
+	}
+	if local := clientB.GetLocal("s3"); local == nil || len(local.EntryList) != 0 {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: failed write was coordinated by node B: %v", local)
+	}
+	time.Sleep(time.Second)
+
+	//Sessions route their writes the same way
+	session := mydynamo.NewSession(clientB)
+	if _, err = session.Get(mydynamo.GetArgs{Key: "s1"}); err != nil {
+		t.Fatalf("TestUnitCoordinatorFromLastRead: session read failed: %v", err)
+	}
+	res = session.Put(mydynamo.PutOptions{Key: "s1", Context: session.Context("", "s1"), Value: []byte("klmno")})
+	if res == nil || res.Coordinator != preferenceList[0] {
+		t.Fail()
+		t.Logf("TestUnitCoordinatorFromLastRead: session write should be coordinated by node A, got %v", res)
+	}
+}
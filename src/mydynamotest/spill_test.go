@@ -0,0 +1,246 @@
+package mydynamotest
+
+import (
+	"bytes"
+	"mydynamo"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestUnitSpillToDisk(t *testing.T) {
+	t.Logf("Starting spill to disk test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18116", "18116")
+	server.SetMemoryBudget(5000)
+	server.SetSpillDir(t.TempDir(), 0)
+	ServeInProcess(server)
+	clientInstance := MakeConnectedClient(18116)
+
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte('a' + i)}, 1000)
+	}
+	for i := 0; i < 10; i++ {
+		if res := clientInstance.Put(PutFreshContext("s"+strconv.Itoa(i), value(i))); !res {
+			t.Fatalf("TestUnitSpillToDisk: put %d failed", i)
+		}
+	}
+	stats := clientInstance.Stats()
+	if stats == nil || stats.SpilledKeys == 0 || stats.MemoryUsed > 5000 || stats.DiskUsed == 0 {
+		t.Fatalf("TestUnitSpillToDisk: cold keys not spilled %v", stats)
+	}
+
+	//Spilled keys are reloaded on read, and keep their clocks
+	for i := 0; i < 10; i++ {
+		got := clientInstance.Get("s" + strconv.Itoa(i))
+		if got == nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, value(i)) {
+			t.Fatalf("TestUnitSpillToDisk: s%d not reloaded", i)
+		}
+		next := mydynamo.NewPutArgs("s"+strconv.Itoa(i), got.EntryList[0].Context, []byte("updated"))
+		if !clientInstance.Put(next) {
+			t.Fatalf("TestUnitSpillToDisk: update of s%d failed", i)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		got := clientInstance.Get("s" + strconv.Itoa(i))
+		if got == nil || len(got.EntryList) != 1 || string(got.EntryList[0].Value) != "updated" {
+			t.Fail()
+			t.Logf("TestUnitSpillToDisk: update of s%d lost or made a sibling %v", i, got)
+		}
+	}
+}
+
+func TestUnitSpillDiskFull(t *testing.T) {
+	t.Logf("Starting full disk test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18117", "18117")
+	server.SetMemoryBudget(3000)
+	server.SetSpillDir(t.TempDir(), 3000)
+	ServeInProcess(server)
+	clientInstance := MakeConnectedClient(18117)
+
+	var err error
+	written := 0
+	for ; written < 20; written++ {
+		_, err = clientInstance.PutStream(mydynamo.PutOptions{
+			Key:     "s" + strconv.Itoa(written),
+			Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+		}, bytes.NewReader(bytes.Repeat([]byte("x"), 1000)))
+		if err != nil {
+			break
+		}
+	}
+	if err != mydynamo.ErrStorageFull {
+		t.Fatalf("TestUnitSpillDiskFull: expected ErrStorageFull, got %v", err)
+	}
+	if written < 4 {
+		t.Fail()
+		t.Logf("TestUnitSpillDiskFull: writes rejected before the disk was full, %d written", written)
+	}
+
+	//Every accepted write is still readable
+	for i := 0; i < written; i++ {
+		got := clientInstance.Get("s" + strconv.Itoa(i))
+		if got == nil || len(got.EntryList) != 1 || len(got.EntryList[0].Value) != 1000 {
+			t.Fail()
+			t.Logf("TestUnitSpillDiskFull: s%d lost", i)
+		}
+	}
+}
+
+func TestUnitSpillConcurrentReads(t *testing.T) {
+	t.Logf("Starting concurrent spilled reads test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18118", "18118")
+	server.SetMemoryBudget(5000)
+	server.SetSpillDir(t.TempDir(), 0)
+	ServeInProcess(server)
+	clientInstance := MakeConnectedClient(18118)
+
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte('a' + i)}, 1000)
+	}
+	for i := 0; i < 10; i++ {
+		if res := clientInstance.Put(PutFreshContext("s"+strconv.Itoa(i), value(i))); !res {
+			t.Fatalf("TestUnitSpillConcurrentReads: put %d failed", i)
+		}
+	}
+
+	//Every read reloads a spilled key and spills another one
+	var wg sync.WaitGroup
+	failed := make(chan int, 8*10*5)
+	for reader := 0; reader < 8; reader++ {
+		wg.Add(1)
+		go func(reader int) {
+			defer wg.Done()
+			client := MakeConnectedClient(18118)
+			defer client.CleanConn()
+			for round := 0; round < 5; round++ {
+				for i := 0; i < 10; i++ {
+					key := (i + reader) % 10
+					got := client.GetLocal("s" + strconv.Itoa(key))
+					if got == nil || len(got.EntryList) != 1 || !valuesEqual(got.EntryList[0].Value, value(key)) {
+						failed <- key
+					}
+				}
+			}
+		}(reader)
+	}
+	wg.Wait()
+	close(failed)
+	for key := range failed {
+		t.Fail()
+		t.Logf("TestUnitSpillConcurrentReads: s%d misread", key)
+	}
+
+	stats := clientInstance.Stats()
+	if stats == nil || stats.SpilledKeys == 0 || stats.MemoryUsed > 5000 {
+		t.Fail()
+		t.Logf("TestUnitSpillConcurrentReads: memory budget not kept %v", stats)
+	}
+}
+
+func TestUnitSpillChargesNetChange(t *testing.T) {
+	t.Logf("Starting memory budget accounting test")
+
+	//No spill directory: writes past the budget fail
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18121", "18121")
+	server.SetMemoryBudget(3000)
+	ServeInProcess(server)
+	clientInstance := MakeConnectedClient(18121)
+
+	if !clientInstance.Put(PutFreshContext("s1", bytes.Repeat([]byte("a"), 2000))) {
+		t.Fatalf("TestUnitSpillChargesNetChange: put failed")
+	}
+	//Overwriting a value with one of the same size adds nothing
+	got := clientInstance.Get("s1")
+	if got == nil || len(got.EntryList) != 1 {
+		t.Fatalf("TestUnitSpillChargesNetChange: get failed")
+	}
+	next := mydynamo.NewPutArgs("s1", got.EntryList[0].Context, bytes.Repeat([]byte("b"), 2000))
+	if !clientInstance.Put(next) {
+		t.Fatalf("TestUnitSpillChargesNetChange: overwrite rejected")
+	}
+	//A stale write is dropped, not rejected for lack of memory
+	if !clientInstance.PutLocalIndexed(mydynamo.IndexedPutArgs{Value: next}) {
+		t.Fail()
+		t.Logf("TestUnitSpillChargesNetChange: stale write rejected")
+	}
+
+	//Secondary index terms count against the budget as well
+	put := func(key string, indexes []mydynamo.IndexTerm) int64 {
+		before := clientInstance.Stats()
+		clientInstance.PutWithOptions(mydynamo.PutOptions{
+			Key:     key,
+			Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+			Value:   []byte("x"),
+			Indexes: indexes,
+		})
+		after := clientInstance.Stats()
+		if before == nil || after == nil {
+			t.Fatalf("TestUnitSpillChargesNetChange: Stats failed")
+		}
+		return after.MemoryUsed - before.MemoryUsed
+	}
+	plain := put("s2", nil)
+	indexed := put("s3", []mydynamo.IndexTerm{{Index: "user", Value: "alice"}})
+	if indexed-plain < int64(len("user")+len("alice")) {
+		t.Fail()
+		t.Logf("TestUnitSpillChargesNetChange: index terms not counted, %d bytes without and %d with", plain, indexed)
+	}
+}
+
+func TestUnitSpillChunks(t *testing.T) {
+	t.Logf("Starting spilled chunks test")
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18122", "18122")
+	server.SetMemoryBudget(int64(2*mydynamo.CHUNK_SIZE + mydynamo.CHUNK_SIZE/2))
+	server.SetSpillDir(t.TempDir(), 0)
+	ServeInProcess(server)
+	clientInstance := MakeConnectedClient(18122)
+
+	value := make([]byte, 4*mydynamo.CHUNK_SIZE)
+	for i := range value {
+		value[i] = byte(i / mydynamo.CHUNK_SIZE)
+	}
+	_, err := clientInstance.PutStream(mydynamo.PutOptions{
+		Key:     "s1",
+		Context: mydynamo.NewContext(mydynamo.NewVectorClock()),
+	}, bytes.NewReader(value))
+	if err != nil {
+		t.Fatalf("TestUnitSpillChunks: PutStream failed %v", err)
+	}
+	stats := clientInstance.Stats()
+	if stats == nil || stats.SpilledChunks == 0 || stats.MemoryUsed > int64(2*mydynamo.CHUNK_SIZE+mydynamo.CHUNK_SIZE/2) {
+		t.Fatalf("TestUnitSpillChunks: chunks not spilled %v", stats)
+	}
+
+	var buf bytes.Buffer
+	if _, err := clientInstance.GetStream(mydynamo.GetArgs{Key: "s1"}, &buf); err != nil || !valuesEqual(buf.Bytes(), value) {
+		t.Fail()
+		t.Logf("TestUnitSpillChunks: spilled chunks not read back %v", err)
+	}
+}
+
+func TestUnitSpillDirCleared(t *testing.T) {
+	t.Logf("Starting spill directory cleanup test")
+
+	//Spill files of a previous process are unusable, their records are gone with it
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "0", "stale")
+	if err := os.MkdirAll(filepath.Dir(stale), 0700); err != nil {
+		t.Fatalf("TestUnitSpillDirCleared: %v", err)
+	}
+	if err := os.WriteFile(stale, []byte("abcde"), 0600); err != nil {
+		t.Fatalf("TestUnitSpillDirCleared: %v", err)
+	}
+
+	server := mydynamo.NewDynamoServer(1, 1, "localhost", "18132", "0")
+	server.SetSpillDir(dir, 0)
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fail()
+		t.Logf("TestUnitSpillDirCleared: stale spill file was kept: %v", err)
+	}
+}
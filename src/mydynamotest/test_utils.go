@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"time"
 )
 
 //Creats a command that will start Dynamo nodes based on the config file specified
@@ -66,3 +67,40 @@ func valuesEqual(v1 []byte, v2 []byte) bool {
 	}
 	return true
 }
+
+//Serves a Dynamo node inside the test process and waits for it to start listening.
+//Used by tests that do not need the DynamoCoordinator binary
+func ServeInProcess(server mydynamo.DynamoServer) {
+	go mydynamo.ServeDynamoServer(server)
+	time.Sleep(500 * time.Millisecond)
+}
+
+//Returns local nodes listening on the given ports
+func LocalNodes(ports ...int) []mydynamo.DynamoNode {
+	nodes := make([]mydynamo.DynamoNode, 0, len(ports))
+	for _, port := range ports {
+		nodes = append(nodes, mydynamo.NewDynamoNode("localhost", strconv.Itoa(port)))
+	}
+	return nodes
+}
+
+//Serves the first up nodes of preferenceList in process, each with the given W and R and
+//the whole preferenceList, leaving the other nodes down. Returns a client connected to each node served
+func ServeNodes(w int, r int, preferenceList []mydynamo.DynamoNode, up int) []*mydynamo.RPCClient {
+	clients := make([]*mydynamo.RPCClient, 0, up)
+	for _, node := range preferenceList[:up] {
+		server := mydynamo.NewDynamoServer(w, r, node.Address, node.Port, node.Port)
+		server.SendPreferenceList(preferenceList, &mydynamo.Empty{})
+		ServeInProcess(server)
+		port, _ := strconv.Atoi(node.Port)
+		clients = append(clients, MakeConnectedClient(port))
+	}
+	return clients
+}
+
+//Serves a cluster of in-process nodes on the given ports, each with the given W and R.
+//Returns a client connected to each node, in the order of ports
+func ServeCluster(w int, r int, ports ...int) []*mydynamo.RPCClient {
+	nodes := LocalNodes(ports...)
+	return ServeNodes(w, r, nodes, len(nodes))
+}
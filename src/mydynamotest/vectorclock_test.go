@@ -148,3 +148,56 @@ func TestCombineVectorClock(t *testing.T) {
 	}
 
 }
+
+func TestDottedVectorClock(t *testing.T) {
+	t.Logf("Starting TestDottedVectorClock")
+
+	//Two writes through node A from the same empty context
+	first := mydynamo.NewVectorClock()
+	first.Dot = mydynamo.Dot{NodeID: "A", Counter: 1}
+	second := mydynamo.NewVectorClock()
+	second.Dot = mydynamo.Dot{NodeID: "A", Counter: 2}
+
+	if !first.Concurrent(second) {
+		t.Fail()
+		t.Logf("writes from the same context should be concurrent")
+	}
+
+	//A write whose context has seen both replaces them
+	third := mydynamo.NewVectorClock()
+	third.Combine([]mydynamo.VectorClock{first, second})
+	third.Dot = mydynamo.Dot{NodeID: "B", Counter: 1}
+	if !first.LessThan(third) || !second.LessThan(third) {
+		t.Fail()
+		t.Logf("should be less than")
+	}
+	if third.VectorClock["A"] != 2 {
+		t.Fail()
+		t.Logf("combine should fold dots into the context")
+	}
+}
+
+func TestPruneVectorClock(t *testing.T) {
+	t.Logf("Starting TestPruneVectorClock")
+
+	clock := mydynamo.NewVectorClock()
+	clock.Increment("A")
+	clock.Increment("B")
+	clock.Increment("C")
+	clock.Timestamps["A"] = 3
+	clock.Timestamps["B"] = 1
+	clock.Timestamps["C"] = 2
+
+	if clock.Prune(3) != 0 {
+		t.Fail()
+		t.Logf("clocks within the threshold should not be pruned")
+	}
+	if clock.Prune(1) != 2 {
+		t.Fail()
+		t.Logf("should prune two entries")
+	}
+	if len(clock.VectorClock) != 1 || clock.VectorClock["A"] != 1 {
+		t.Fail()
+		t.Logf("should keep the most recently updated entry")
+	}
+}